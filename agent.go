@@ -2,6 +2,7 @@ package main
 
 import (
 	"crypto/md5"
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/url"
@@ -29,11 +30,57 @@ func noAuthKey(err error) *NoAuthKeyErr {
 	}
 }
 
+type profile struct {
+	Proxy string `yaml:"PROXY"`
+}
+
 type config struct {
-	ConfigPath string `yaml:"ZOOMEYE_CONFIG_PATH"`
-	CachePath  string `yaml:"ZOOMEYE_CACHE_PATH"`
-	DataPath   string `yaml:"ZOOMEYE_DATA_PATH"`
-	ExpiredSec uint   `yaml:"EXPIRED_TIME"`
+	ConfigPath  string              `yaml:"ZOOMEYE_CONFIG_PATH"`
+	CachePath   string              `yaml:"ZOOMEYE_CACHE_PATH"`
+	DataPath    string              `yaml:"ZOOMEYE_DATA_PATH"`
+	ExpiredSec  uint                `yaml:"EXPIRED_TIME"`
+	TLSInsecure bool                `yaml:"TLS_INSECURE"`
+	TLSCABundle string              `yaml:"TLS_CA_BUNDLE"`
+	TLSPins     []string            `yaml:"TLS_PINS"`
+	Proxy       string              `yaml:"PROXY"`
+	Profile     string              `yaml:"PROFILE"`
+	Profiles    map[string]*profile `yaml:"PROFILES"`
+	Sinks       []*sinkConfig       `yaml:"SINKS,omitempty"`
+	// overrides by command line flags, they are never written into conf.yml
+	proxy    string
+	insecure bool
+	caBundle string
+	profile  string
+	debug    bool
+	debugDir string
+}
+
+func (c *config) tlsInsecure() bool {
+	return c.insecure || c.TLSInsecure
+}
+
+func (c *config) tlsCABundle() string {
+	if c.caBundle != "" {
+		return c.caBundle
+	}
+	return c.TLSCABundle
+}
+
+func (c *config) profileName() string {
+	if c.profile != "" {
+		return c.profile
+	}
+	return c.Profile
+}
+
+func (c *config) proxyURL() string {
+	if c.proxy != "" {
+		return c.proxy
+	}
+	if p, ok := c.Profiles[c.profileName()]; ok && p != nil && p.Proxy != "" {
+		return p.Proxy
+	}
+	return c.Proxy
 }
 
 func (c *config) check() {
@@ -118,12 +165,49 @@ func (a *ZoomEyeAgent) cache(name string, result interface{}) error {
 	return writeObject(filepath.Join(a.conf.CachePath, name), result)
 }
 
+func (a *ZoomEyeAgent) newZoom(apiKey, accessToken string) (*zoomeye.ZoomEye, error) {
+	var opts []zoomeye.Option
+	if a.conf.tlsInsecure() {
+		opts = append(opts, zoomeye.WithInsecure())
+	} else if a.conf.tlsCABundle() != "" {
+		pool, err := zoomeye.LoadCABundle(abs(a.conf.tlsCABundle()))
+		if err != nil {
+			return nil, fmt.Errorf("invalid CA bundle: %v", err)
+		}
+		opts = append(opts, zoomeye.WithRootCAs(pool))
+	}
+	if len(a.conf.TLSPins) > 0 {
+		if _, err := zoomeye.ParsePinnedKeys(a.conf.TLSPins...); err != nil {
+			return nil, fmt.Errorf("invalid TLS pins: %v", err)
+		}
+		opts = append(opts, zoomeye.WithPinnedKeys(a.conf.TLSPins...))
+	}
+	if name := a.conf.profileName(); name != "" {
+		if _, ok := a.conf.Profiles[name]; !ok {
+			return nil, fmt.Errorf("profile %s not found", name)
+		}
+	}
+	if s := a.conf.proxyURL(); s != "" {
+		proxy, err := zoomeye.ParseProxy(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy: %v", err)
+		}
+		opts = append(opts, zoomeye.WithProxy(proxy))
+	}
+	opts = append(opts, zoomeye.WithMiddleware(newLedger(a.conf).middleware()))
+	if a.conf.debug {
+		opts = append(opts, zoomeye.WithTracer(zoomeye.LogTracer(os.Stderr, a.conf.debugDir)))
+	}
+	return zoomeye.NewWithKey(apiKey, accessToken, opts...), nil
+}
+
 // InitByKey initializes ZoomEye by API-Key
 func (a *ZoomEyeAgent) InitByKey(apiKey string) (*zoomeye.ResourcesInfoResult, error) {
-	var (
-		zoom        = zoomeye.NewWithKey(apiKey, "")
-		result, err = zoom.ResourcesInfo()
-	)
+	zoom, err := a.newZoom(apiKey, "")
+	if err != nil {
+		return nil, err
+	}
+	result, err := zoom.ResourcesInfo()
 	if err != nil {
 		return nil, err
 	}
@@ -136,10 +220,11 @@ func (a *ZoomEyeAgent) InitByKey(apiKey string) (*zoomeye.ResourcesInfoResult, e
 
 // InitByUser initializes ZoomEye by username/password
 func (a *ZoomEyeAgent) InitByUser(username, password string) (*zoomeye.ResourcesInfoResult, error) {
-	var (
-		zoom     = zoomeye.New()
-		tok, err = zoom.Login(username, password)
-	)
+	zoom, err := a.newZoom("", "")
+	if err != nil {
+		return nil, err
+	}
+	tok, err := zoom.Login(username, password)
 	if err != nil {
 		return nil, err
 	}
@@ -184,7 +269,10 @@ func (a *ZoomEyeAgent) InitLocal() (*zoomeye.ResourcesInfoResult, error) {
 			return nil, err
 		}
 	}
-	zoom := zoomeye.NewWithKey(apiKey, accessToken)
+	zoom, err := a.newZoom(apiKey, accessToken)
+	if err != nil {
+		return nil, err
+	}
 	if result, err = zoom.ResourcesInfo(); err != nil {
 		return nil, err
 	}
@@ -212,27 +300,31 @@ func (a *ZoomEyeAgent) fromLocal(name string) (*zoomeye.SearchResult, bool) {
 	return result, true
 }
 
-func (a *ZoomEyeAgent) forceSearch(dork string, maxPage int, resource string) (*zoomeye.SearchResult, error) {
-	results, err := a.zoom.MultiPageSearch(dork, maxPage, resource, "")
-	if err != nil {
-		return nil, err
-	}
-	result := &zoomeye.SearchResult{
-		Type: resource,
-	}
-	for i, n := 0, len(results); i < maxPage && n > 0; i++ {
-		page := i + 1
-		if res, ok := results[page]; ok {
-			a.cache(filename(resource, dork, page, true), res)
-			result.Extend(res)
-			n--
+// searchPages searches the pages concurrently, the successful ones are cached and put into results
+func (a *ZoomEyeAgent) searchPages(dork string, pages []int, resource string, results map[int]*zoomeye.SearchResult, opts ...zoomeye.SearchOption) (map[int]error, error) {
+	errs := make(map[int]error)
+	opts = append(opts[:len(opts):len(opts)], zoomeye.WithPageHandler(func(page int, res *zoomeye.SearchResult, err error) {
+		if err != nil {
+			errs[page] = err
+			return
 		}
-	}
-	return result, nil
+		a.cache(filename(resource, dork, page, true), res)
+		results[page] = res
+	}))
+	_, err := a.zoom.SearchPages(dork, pages, resource, "", opts...)
+	return errs, err
+}
+
+// Search gets search results from local, cache or API, the pages missing in cache are searched concurrently
+// after the first one, which limits the pages by total, *zoomeye.PartialResultError is returned together
+// with the result if some pages failed
+func (a *ZoomEyeAgent) Search(dork string, num int, resource string, force bool, opts ...zoomeye.SearchOption) (*zoomeye.SearchResult, error) {
+	return a.RetrySearch(dork, num, resource, force, 0, nil, opts...)
 }
 
-// Search gets search results from local, cache or API
-func (a *ZoomEyeAgent) Search(dork string, num int, resource string, force bool) (*zoomeye.SearchResult, error) {
+// RetrySearch is Search that searches the failed pages again for retry times at most,
+// only the failed pages are requested by each retry, and onRetry is called with them before
+func (a *ZoomEyeAgent) RetrySearch(dork string, num int, resource string, force bool, retry int, onRetry func(pages []int), opts ...zoomeye.SearchOption) (*zoomeye.SearchResult, error) {
 	if a.zoom == nil {
 		if _, err := a.InitLocal(); err != nil {
 			return nil, err
@@ -248,35 +340,82 @@ func (a *ZoomEyeAgent) Search(dork string, num int, resource string, force bool)
 	if resource = strings.ToLower(resource); resource != "web" {
 		resource = "host"
 	}
+	var (
+		results = make(map[int]*zoomeye.SearchResult)
+		errs    map[int]error
+		retried int
+	)
 	if force {
-		return a.forceSearch(dork, maxPage, resource)
-	}
-	result, ok := a.fromLocal(filename(resource, url.QueryEscape(dork), num, false))
-	if ok {
-		result.Type = resource
-		return result, nil
-	}
-	result = &zoomeye.SearchResult{
-		Type: resource,
-	}
-	for i := 0; i < maxPage; i++ {
-		var (
-			res  = &zoomeye.SearchResult{}
-			page = i + 1
-			name = filename(resource, dork, page, true)
-		)
-		if !a.fromCache(name, res) {
+		res, err := a.zoom.MultiPageSearch(dork, maxPage, resource, "", opts...)
+		if res == nil {
+			return nil, err
+		}
+		for page, v := range res {
+			a.cache(filename(resource, dork, page, true), v)
+			results[page] = v
+		}
+		if partial, ok := err.(*zoomeye.PartialResultError); ok {
+			errs = partial.Pages
+		}
+	} else {
+		result, ok := a.fromLocal(filename(resource, url.QueryEscape(dork), num, false))
+		if ok {
+			result.Type = resource
+			return result, nil
+		}
+		// the first page decides how many pages there are, so that no quota is spent on pages after the last one
+		if res := (&zoomeye.SearchResult{}); a.fromCache(filename(resource, dork, 1, true), res) {
+			results[1] = res
+		} else {
 			var err error
-			if res, err = a.zoom.DorkSearch(dork, page, resource, ""); err != nil {
+			errs, err = a.searchPages(dork, []int{1}, resource, results, opts...)
+			for ; results[1] == nil && retried < retry; retried++ {
+				if onRetry != nil {
+					onRetry([]int{1})
+				}
+				errs, err = a.searchPages(dork, []int{1}, resource, results, opts...)
+			}
+			if results[1] == nil {
 				return nil, err
 			}
-			a.cache(name, res)
 		}
-		result.Extend(res)
+		if n := int((results[1].Total + 19) / 20); n > 0 && n < maxPage {
+			maxPage = n
+		}
+		var missing []int
+		for page := 2; page <= maxPage; page++ {
+			res := &zoomeye.SearchResult{}
+			if a.fromCache(filename(resource, dork, page, true), res) {
+				results[page] = res
+			} else {
+				missing = append(missing, page)
+			}
+		}
+		if len(missing) > 0 {
+			errs, _ = a.searchPages(dork, missing, resource, results, opts...)
+		}
+	}
+	for ; retried < retry && len(errs) > 0; retried++ {
+		pages := (&zoomeye.PartialResultError{Pages: errs}).FailedPages()
+		if onRetry != nil {
+			onRetry(pages)
+		}
+		errs, _ = a.searchPages(dork, pages, resource, results, opts...)
+	}
+	result := &zoomeye.SearchResult{
+		Type: resource,
+	}
+	for page := 1; page <= maxPage; page++ {
+		result.Extend(results[page])
 	}
 	if num < len(result.Matches) {
 		result.Matches = result.Matches[:num]
 	}
+	if len(errs) > 0 {
+		return result, &zoomeye.PartialResultError{
+			Pages: errs,
+		}
+	}
 	return result, nil
 }
 
@@ -302,18 +441,31 @@ func (a *ZoomEyeAgent) History(ip string, force bool) (*zoomeye.HistoryResult, e
 	if net.ParseIP(ip) == nil {
 		return nil, fmt.Errorf("invalid ip address")
 	}
+	if err := a.checkHistoryPlan(); err != nil {
+		return nil, err
+	}
+	result, _, err := a.history(ip, force)
+	return result, err
+}
+
+func (a *ZoomEyeAgent) checkHistoryPlan() error {
 	info, err := a.Info()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	switch strings.ToLower(info.Plan) {
 	case "user", "developer":
-		return nil, fmt.Errorf("this function is only open to advanced users and VIP users.")
+		return fmt.Errorf("this function is only open to advanced users and VIP users.")
 	}
+	return nil
+}
+
+func (a *ZoomEyeAgent) history(ip string, force bool) (*zoomeye.HistoryResult, bool, error) {
 	var (
 		result *zoomeye.HistoryResult
 		name   = fmt.Sprintf("%x", md5.Sum([]byte("history_"+ip))) + ".json"
 		ok     bool
+		err    error
 	)
 	if !force {
 		result = &zoomeye.HistoryResult{}
@@ -321,10 +473,10 @@ func (a *ZoomEyeAgent) History(ip string, force bool) (*zoomeye.HistoryResult, e
 	}
 	if !ok {
 		if result, err = a.zoom.HistoryIP(ip); err != nil {
-			return nil, err
+			return nil, false, err
 		}
 		if result.Count == 0 || len(result.Data) == 0 {
-			return result, nil
+			return result, false, nil
 		}
 		a.cache(name, result)
 	}
@@ -336,7 +488,7 @@ func (a *ZoomEyeAgent) History(ip string, force bool) (*zoomeye.HistoryResult, e
 		}
 	}
 	result.Count = uint64(len(result.Data))
-	return result, nil
+	return result, ok, nil
 }
 
 // Clear removes all cache or setting data
@@ -361,16 +513,84 @@ func (a *ZoomEyeAgent) SaveFilterData(path string, data []map[string]interface{}
 	return writeObject(path, data)
 }
 
-// Save writes the search results (and filter data) to local file
-func (a *ZoomEyeAgent) Save(name string, result *zoomeye.SearchResult) (string, error) {
+func (a *ZoomEyeAgent) save(name string, v interface{}) (string, error) {
 	path := filepath.Join(a.conf.DataPath, name+".json")
-	if err := writeObject(path, result); err != nil {
+	if err := writeObject(path, v); err != nil {
 		return "", err
 	}
 	path, _ = filepath.Abs(path)
 	return path, nil
 }
 
+// Save writes the search results (and filter data) to local file
+func (a *ZoomEyeAgent) Save(name string, result *zoomeye.SearchResult) (string, error) {
+	return a.save(name, result)
+}
+
+// historyColumns is the order of columns when exporting history data
+var historyColumns = []string{"ip", "time", "port", "service", "app", "raw"}
+
+// SaveHistory writes the history results to local file
+func (a *ZoomEyeAgent) SaveHistory(name string, result *zoomeye.HistoryResult) (string, error) {
+	return a.save(name, result)
+}
+
+// ExportHistory writes the filtered history data to local file in JSON or CSV format
+func (a *ZoomEyeAgent) ExportHistory(path string, data []map[string]interface{}, format string) error {
+	if len(data) == 0 {
+		return fmt.Errorf("no any filter datas")
+	}
+	if strings.ToLower(format) != "csv" {
+		return writeObject(path, data)
+	}
+	var columns []string
+	for _, k := range historyColumns {
+		if _, ok := data[0][k]; ok {
+			columns = append(columns, k)
+		}
+	}
+	return writeCSV(path, columns, data)
+}
+
+// isHistoryFile checks whether the local file is saved from history results
+func isHistoryFile(path string) bool {
+	var m map[string]json.RawMessage
+	if err := readObject(&m, path); err != nil {
+		return false
+	}
+	_, data := m["data"]
+	_, matches := m["matches"]
+	return data && !matches
+}
+
+// isHistoryFiles reports whether all the files are history results,
+// error is returned if search and history results are mixed
+func isHistoryFiles(files []string) (bool, error) {
+	var history, search []string
+	for _, v := range files {
+		if isHistoryFile(v) {
+			history = append(history, v)
+		} else {
+			search = append(search, v)
+		}
+	}
+	if len(history) > 0 && len(search) > 0 {
+		return false, fmt.Errorf("search results (%s) and history results (%s) can not be loaded together",
+			strings.Join(search, ", "), strings.Join(history, ", "))
+	}
+	return len(history) > 0, nil
+}
+
+// LoadHistory reads the history results from local file
+func (a *ZoomEyeAgent) LoadHistory(path string) (*zoomeye.HistoryResult, error) {
+	result := &zoomeye.HistoryResult{}
+	if err := readObject(result, path); err != nil {
+		return nil, err
+	}
+	result.Count = uint64(len(result.Data))
+	return result, nil
+}
+
 // NewAgent creates instance of ZoomEyeAgent
 func NewAgent() *ZoomEyeAgent {
 	return &ZoomEyeAgent{
@@ -0,0 +1,487 @@
+package main
+
+import (
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gyyyy/ZoomEye-go/zoomeye"
+	"github.com/gyyyy/ZoomEye-go/zoomeye/zoomeyetest"
+)
+
+func tAgent(t *testing.T, srv *zoomeyetest.Server) *ZoomEyeAgent {
+	var (
+		dir  = t.TempDir()
+		conf = &config{
+			ConfigPath: filepath.Join(dir, "setting"),
+			CachePath:  filepath.Join(dir, "cache"),
+			DataPath:   filepath.Join(dir, "data"),
+			ExpiredSec: 3600,
+		}
+	)
+	conf.check()
+	return &ZoomEyeAgent{
+		zoom: zoomeye.NewWithKey(zoomeyetest.APIKey, "", zoomeye.WithBaseURL(srv.URL)),
+		conf: conf,
+	}
+}
+
+func TestAgentSearch(t *testing.T) {
+	srv := zoomeyetest.NewServer()
+	defer srv.Close()
+	agent := tAgent(t, srv)
+	result, err := agent.Search("nginx", 30, "host", false)
+	if err != nil || len(result.Matches) != 30 || srv.Requests("/host/search") != 2 {
+		t.FailNow()
+	}
+	if result, err = agent.Search("nginx", 40, "host", false); err != nil || len(result.Matches) != 40 {
+		t.FailNow()
+	}
+	if srv.Requests("/host/search") != 2 {
+		t.Fail()
+	}
+	if _, err = agent.Search("nginx", 40, "host", true); err != nil || srv.Requests("/host/search") != 4 {
+		t.Fail()
+	}
+}
+
+func TestAgentHistory(t *testing.T) {
+	srv := zoomeyetest.NewServer()
+	defer srv.Close()
+	agent := tAgent(t, srv)
+	for i := 0; i < 2; i++ {
+		result, err := agent.History("1.2.3.4", false)
+		if err != nil || result.Count != 30 {
+			t.FailNow()
+		}
+	}
+	if srv.Requests("/both/search") != 1 {
+		t.Fail()
+	}
+}
+
+func TestAgentPartialSearch(t *testing.T) {
+	srv := zoomeyetest.NewServer(zoomeyetest.WithFaults(&zoomeyetest.Fault{Path: "/host/search", Page: 2, Status: http.StatusBadGateway, Times: 1}))
+	defer srv.Close()
+	agent := tAgent(t, srv)
+	result, err := agent.Search("", 60, "host", false)
+	if partial, ok := err.(*zoomeye.PartialResultError); !ok || len(partial.Pages) != 1 || len(result.Matches) != 40 {
+		t.FailNow()
+	}
+	if result, err = agent.Search("", 60, "host", false); err != nil || len(result.Matches) != 60 {
+		t.FailNow()
+	}
+	if srv.Requests("/host/search") != 4 {
+		t.Fail()
+	}
+	srv = zoomeyetest.NewServer(zoomeyetest.WithFaults(&zoomeyetest.Fault{Path: "/host/search", Page: 2, Status: http.StatusBadGateway, Times: 1}))
+	defer srv.Close()
+	agent = tAgent(t, srv)
+	var retried []int
+	result, err = agent.RetrySearch("", 60, "host", true, 1, func(pages []int) {
+		retried = append(retried, pages...)
+	})
+	if err != nil || len(result.Matches) != 60 || len(retried) != 1 || retried[0] != 2 || srv.Requests("/host/search") != 4 {
+		t.Fail()
+	}
+}
+
+func TestAgentSearchPastEnd(t *testing.T) {
+	srv := zoomeyetest.NewServer()
+	defer srv.Close()
+	var (
+		agent = tAgent(t, srv)
+		pages []int
+	)
+	result, err := agent.Search("nginx", 100, "host", false, zoomeye.WithPageHandler(func(page int, _ *zoomeye.SearchResult, _ error) {
+		pages = append(pages, page)
+	}))
+	if err != nil || len(result.Matches) != 40 || len(pages) != 2 || srv.Requests("/host/search") != 2 {
+		t.FailNow()
+	}
+}
+
+func TestLedger(t *testing.T) {
+	srv := zoomeyetest.NewServer()
+	defer srv.Close()
+	agent := tAgent(t, srv)
+	agent.conf.Profile = "jumphost"
+	agent.zoom = zoomeye.NewWithKey(zoomeyetest.APIKey, "", zoomeye.WithBaseURL(srv.URL),
+		zoomeye.WithMiddleware(newLedger(agent.conf).middleware()))
+	if _, err := agent.Search("nginx", 30, "host", false); err != nil {
+		t.FailNow()
+	}
+	if _, err := agent.History("1.2.3.4", false); err != nil {
+		t.FailNow()
+	}
+	entries, err := agent.Usage(time.Now().Add(-time.Hour))
+	if err != nil || len(entries) != 4 {
+		t.FailNow()
+	}
+	var cost int
+	for _, e := range entries {
+		if e.Profile != "jumphost" {
+			t.Fail()
+		}
+		cost += e.Cost
+	}
+	if search, _ := srv.Quota(); cost != 40+30 || search != 10000-cost {
+		t.Fail()
+	}
+	if reset, ok := nextReset("month", time.Date(2021, 12, 15, 8, 0, 0, 0, time.UTC)); !ok || reset.Format("2006-01-02") != "2022-01-01" {
+		t.Fail()
+	}
+}
+
+func TestAgentBatchSearch(t *testing.T) {
+	srv := zoomeyetest.NewServer(zoomeyetest.WithQuota(30, 0))
+	defer srv.Close()
+	agent := tAgent(t, srv)
+	result, items, err := agent.BatchSearch([]string{"nginx", "apache", "iis"}, 20, "host", false, 10)
+	if err != nil || len(items) != 3 || len(result.Matches) != 20 {
+		t.FailNow()
+	}
+	if items[0].Status != "ok" || items[1].Status != "skipped" || items[2].Status != "skipped" || srv.Requests("/resources-info") != 1 {
+		t.Fail()
+	}
+	for _, m := range result.Matches {
+		if m["_dork"] != "nginx" {
+			t.Fail()
+		}
+	}
+}
+
+func TestAgentBatchHistory(t *testing.T) {
+	srv := zoomeyetest.NewServer(zoomeyetest.WithHistory("10.0.0.1", zoomeyetest.History("10.0.0.1", 5)...))
+	defer srv.Close()
+	agent := tAgent(t, srv)
+	ips, err := expandIPs([]string{"10.0.0.0/30", "1.2.3.4", "10.0.0.1"})
+	if err != nil || len(ips) != 3 || ips[0] != "10.0.0.1" || ips[2] != "1.2.3.4" {
+		t.FailNow()
+	}
+	if _, err = agent.History("1.2.3.4", false); err != nil {
+		t.FailNow()
+	}
+	result, items, err := agent.BatchHistory(ips, false, 2, nil)
+	if err != nil || result.Count != 35 || len(items) != 3 {
+		t.FailNow()
+	}
+	if items[0].Probes != 5 || items[1].Status != "empty" || !items[2].Cached {
+		t.Fail()
+	}
+	if srv.Requests("/both/search") != 3 {
+		t.Fail()
+	}
+}
+
+func TestAgentShardSearch(t *testing.T) {
+	srv := zoomeyetest.NewServer()
+	defer srv.Close()
+	agent := tAgent(t, srv)
+	result, report, err := agent.ShardSearch("", 1000, "host", false, []string{"country", "port"}, 30)
+	if err != nil || len(report.Shards) < 2 {
+		t.FailNow()
+	}
+	if report.Total != 200 || report.Unique != 200 || len(result.Matches) != 200 || report.Coverage() != 1 {
+		t.Fail()
+	}
+	for _, s := range report.Shards {
+		if s.Total > 30 && !s.Capped {
+			t.Fail()
+		}
+	}
+	agent = tAgent(t, srv)
+	agent.zoom = agent.zoom.With(func(next http.RoundTripper) http.RoundTripper {
+		return zoomeye.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if !strings.Contains(req.URL.Query().Get("query"), `+country:"China"`) {
+				return next.RoundTrip(req)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       ioutil.NopCloser(strings.NewReader(`{"total":0,"available":0,"matches":[]}`)),
+				Request:    req,
+			}, nil
+		})
+	})
+	if result, report, err = agent.ShardSearch("", 1000, "host", false, []string{"country", "port"}, 30); err != nil {
+		t.FailNow()
+	}
+	var empty int
+	for _, s := range report.Shards {
+		if s.Status == "empty" && strings.Contains(s.Dork, `+country:"China"`) {
+			empty++
+		}
+	}
+	if empty != 1 || report.Unique != 160 || len(result.Matches) != 160 {
+		t.Fail()
+	}
+}
+
+func TestAgentIncrementalSearch(t *testing.T) {
+	srv := zoomeyetest.NewServer()
+	defer srv.Close()
+	agent := tAgent(t, srv)
+	result, err := agent.IncrementalSearch("", 20, "host")
+	if err != nil || result.Since != "" || len(result.New.Matches) != 20 || result.State.Newest != "2021-03-01T00:00:00" {
+		t.FailNow()
+	}
+	if result, err = agent.IncrementalSearch("", 20, "host"); err != nil || len(result.New.Matches) != 0 || len(result.Merged.Matches) != 20 {
+		t.FailNow()
+	}
+	var (
+		hosts = zoomeyetest.Hosts(200)
+		newer = zoomeyetest.Hosts(201)[200]
+	)
+	newer["timestamp"] = "2021-03-02T08:00:00"
+	srv2 := zoomeyetest.NewServer(zoomeyetest.WithHosts(append([]map[string]interface{}{newer}, hosts...)...))
+	defer srv2.Close()
+	agent.zoom = zoomeye.NewWithKey(zoomeyetest.APIKey, "", zoomeye.WithBaseURL(srv2.URL))
+	result, err = agent.IncrementalSearch("", 20, "host")
+	if err != nil || result.Since != "2021-03-01" || len(result.New.Matches) != 1 || len(result.Merged.Matches) != 21 {
+		t.FailNow()
+	}
+	if result.State.Newest != "2021-03-02T08:00:00" {
+		t.Fail()
+	}
+	srv3 := zoomeyetest.NewServer(zoomeyetest.WithFaults(&zoomeyetest.Fault{Path: "/host/search", Page: 2, Status: http.StatusBadGateway, Times: 1}))
+	defer srv3.Close()
+	agent.zoom = zoomeye.NewWithKey(zoomeyetest.APIKey, "", zoomeye.WithBaseURL(srv3.URL))
+	result, err = agent.IncrementalSearch("nginx", 40, "host")
+	if _, ok := err.(*zoomeye.PartialResultError); !ok || len(result.New.Matches) != 20 || result.State.Newest != "" {
+		t.FailNow()
+	}
+	if result, err = agent.IncrementalSearch("nginx", 40, "host"); err != nil || result.Since != "" || len(result.New.Matches) != 20 || result.State.Newest == "" {
+		t.Fail()
+	}
+}
+
+func TestHistoryDiff(t *testing.T) {
+	srv := zoomeyetest.NewServer()
+	defer srv.Close()
+	agent := tAgent(t, srv)
+	result, err := agent.History("1.2.3.4", false)
+	if err != nil {
+		t.FailNow()
+	}
+	diffs, probes := bannerDiffs(result, 80)
+	if probes != 10 || len(diffs) != 3 {
+		t.FailNow()
+	}
+	d := diffs[0]
+	if len(d.Hunks) != 1 || d.Hunks[0].String() != "@@ -1,3 +1,3 @@" || d.From >= d.To {
+		t.Fail()
+	}
+	if len(d.Headers) != 1 || d.Headers[0] != "~ Server: nginx/1.18.0 => nginx/1.18.0.1" {
+		t.Fail()
+	}
+}
+
+func TestHistorySaveLoad(t *testing.T) {
+	srv := zoomeyetest.NewServer()
+	defer srv.Close()
+	agent := tAgent(t, srv)
+	result, err := agent.History("1.2.3.4", false)
+	if err != nil {
+		t.FailNow()
+	}
+	path, err := agent.SaveHistory("history_1.2.3.4", result)
+	if err != nil || !isHistoryFile(path) {
+		t.FailNow()
+	}
+	csvPath := filepath.Join(agent.conf.DataPath, "history_1.2.3.4_filtered.csv")
+	if err = agent.ExportHistory(csvPath, result.Filter("time", "port", "ip"), "csv"); err != nil {
+		t.FailNow()
+	}
+	if b, _ := readFile(csvPath); !strings.HasPrefix(string(b), "ip,time,port\n1.2.3.4,2021-03-01T00:00:00,80\n") {
+		t.Fail()
+	}
+	search, _ := agent.Search("nginx", 20, "host", false)
+	searchPath, _ := agent.Save("host_nginx_20", search)
+	if isHistoryFile(searchPath) {
+		t.Fail()
+	}
+	if history, err := isHistoryFiles([]string{path, path}); err != nil || !history {
+		t.Fail()
+	}
+	if history, err := isHistoryFiles([]string{searchPath}); err != nil || history {
+		t.Fail()
+	}
+	if _, err = isHistoryFiles([]string{searchPath, path}); err == nil {
+		t.Fail()
+	}
+	loaded, err := agent.LoadHistory(filepath.Join(agent.conf.DataPath, "history_1.2.3.4.json"))
+	if err != nil || loaded.Count != 30 || len(loaded.Timeline(30*24*time.Hour)) != 3 {
+		t.Fail()
+	}
+}
+
+func TestRenderChart(t *testing.T) {
+	srv := zoomeyetest.NewServer()
+	defer srv.Close()
+	agent := tAgent(t, srv)
+	result, err := agent.Search("nginx", 20, "host", false)
+	if err != nil {
+		t.FailNow()
+	}
+	series := newChartSeries("Result Statistics", statBody(result, []string{"country", "app"}))
+	if len(series) != 2 || series[0].Title != "Result Statistics: app" {
+		t.FailNow()
+	}
+	dir := t.TempDir()
+	for _, kind := range []string{"pie", "hist", "stack"} {
+		svgPath := filepath.Join(dir, kind+".svg")
+		if err = renderChart(svgPath, kind, series); err != nil {
+			t.FailNow()
+		}
+		if b, _ := readFile(svgPath); !strings.HasPrefix(string(b), "<svg") || !strings.Contains(string(b), "Result Statistics: country") {
+			t.Fail()
+		}
+		pngPath := filepath.Join(dir, kind+".png")
+		if err = renderChart(pngPath, kind, series); err != nil {
+			t.FailNow()
+		}
+		f, err := os.Open(pngPath)
+		if err != nil {
+			t.FailNow()
+		}
+		img, err := png.Decode(f)
+		f.Close()
+		if err != nil || img.Bounds().Dx() != chartWidth {
+			t.Fail()
+		}
+	}
+	if renderChart(filepath.Join(dir, "chart.jpg"), "pie", series) == nil {
+		t.Fail()
+	}
+}
+
+func TestZeroCounts(t *testing.T) {
+	var (
+		body   = map[string][][]interface{}{"app": {{"nginx", uint64(0), float64(0)}, {"OpenSSH", uint64(0), float64(0)}}}
+		series = newChartSeries("Result Statistics", body)
+	)
+	histf("ZERO - HIST", body)
+	stackf("ZERO - STACK", body)
+	for _, kind := range []string{"pie", "hist", "stack"} {
+		if strings.Contains(chartSVG(kind, series), "NaN") {
+			t.Fail()
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	srv := zoomeyetest.NewServer()
+	defer srv.Close()
+	agent := tAgent(t, srv)
+	result, err := agent.Search("nginx", 20, "host", false)
+	if err != nil {
+		t.FailNow()
+	}
+	path := filepath.Join(t.TempDir(), "report.html")
+	meta := &reportMeta{Dork: `nginx <script>`, Source: "search"}
+	if err = writeReport(path, result, meta, []string{"app"}, []string{"country"}, "pie"); err != nil {
+		t.FailNow()
+	}
+	b, _ := readFile(path)
+	s := string(b)
+	if strings.Contains(s, "nginx <script>") || !strings.Contains(s, "nginx &lt;script&gt;") {
+		t.Fail()
+	}
+	if strings.Count(s, "<svg") != 2 || strings.Count(s, "<details>") != len(result.Matches) {
+		t.Fail()
+	}
+	if dorkOfFile("data/host_app%3Anginx+country%3AChina_20.json") != "app:nginx country:China" {
+		t.Fail()
+	}
+}
+
+func TestMarkdownEscape(t *testing.T) {
+	cases := map[string]string{
+		"a|b":                    `a\|b`,
+		"HTTP/1.1 200\r\nX: y\n": "HTTP/1.1 200<br>X: y<br>",
+		"J\x00\x1b\x7f":          "J␀␛␡",
+		"<b>日本</b>":              "&lt;b&gt;日本&lt;/b&gt;",
+		`OpenSSH_7.4 *`:          `OpenSSH\_7.4 \*`,
+	}
+	for in, out := range cases {
+		if s := mdEscape(in, 0); s != out {
+			t.Errorf("mdEscape(%q) = %q, want %q", in, s, out)
+		}
+	}
+	if s := mdEscape("日本語テキスト", 6); s != "日本語..." {
+		t.Fail()
+	}
+	// the entry of unexpected shape is skipped instead of panicking
+	mdHtablef("Facets", []map[string]interface{}{
+		{"name": "app", "items": []interface{}{"nginx"}},
+		{"name": "os", "items": []map[string]interface{}{{"key": "Linux", "value": 1}}},
+	}, [3]int{10, 10, 10}, true)
+}
+
+func TestWorldMap(t *testing.T) {
+	initMap()
+	cn, ok1 := mapIndex["china"]
+	jp, ok2 := mapIndex["日本"]
+	if !ok1 || !ok2 || mapIndex["jp"] != jp || mapIndex["united states"] != mapIndex["us"] {
+		t.FailNow()
+	}
+	cells := make(map[int]int)
+	for _, row := range mapGrid {
+		for _, i := range row {
+			cells[i]++
+		}
+	}
+	if cells[cn] < 10 || cells[jp] == 0 || cells[mapIndex["br"]] < 10 || cells[-2] < mapCols*mapRows/2 {
+		t.FailNow()
+	}
+	if mapLevel(0, 100) != 0 || mapLevel(1, 100) != 1 || mapLevel(100, 100) != mapLevels || mapLevel(3, 1000) != 1 {
+		t.Fail()
+	}
+	s := worldMap(map[int]uint64{cn: 100, jp: 1}, 100)
+	if strings.Count(s, "\n") != mapRows-1 || strings.Count(s, mapChars[mapLevels-1]) != cells[cn] || strings.Count(s, mapChars[0]) != cells[jp] {
+		t.Fail()
+	}
+	if ansiRegexp.ReplaceAllString(mapLegend(100), "") != "░ 1-2   ▒ 3-9   ▓ 10-30   █ 31-100   · 0" {
+		t.Fail()
+	}
+}
+
+func TestInvalidPins(t *testing.T) {
+	srv := zoomeyetest.NewServer()
+	defer srv.Close()
+	agent := tAgent(t, srv)
+	for pins, valid := range map[string]bool{"not base64": false, " ": false, "sha256/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=": true} {
+		agent.conf.TLSPins = []string{pins}
+		if _, err := agent.newZoom(zoomeyetest.APIKey, ""); (err == nil) != valid {
+			t.Fail()
+		}
+	}
+}
+
+func TestDebugFromEnv(t *testing.T) {
+	defer os.Setenv("ZOOMEYE_DEBUG", os.Getenv("ZOOMEYE_DEBUG"))
+	for s, want := range map[string]bool{"": false, "0": false, "false": false, "1": true, "true": true, "yes": true} {
+		os.Setenv("ZOOMEYE_DEBUG", s)
+		if debugFromEnv() != want {
+			t.Fail()
+		}
+	}
+}
+
+func TestClearSetting(t *testing.T) {
+	srv := zoomeyetest.NewServer()
+	defer srv.Close()
+	agent := tAgent(t, srv)
+	agent.conf.insecure, agent.conf.caBundle, agent.conf.profile = true, "ca.pem", "jumphost"
+	agent.Clear(false, true)
+	b, err := readFile(filepath.Join(agent.conf.ConfigPath, "conf.yml"))
+	if err != nil || strings.Contains(string(b), "TLS_INSECURE: true") || strings.Contains(string(b), "ca.pem") || strings.Contains(string(b), "jumphost") {
+		t.Fail()
+	}
+}
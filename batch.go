@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gyyyy/ZoomEye-go/zoomeye"
+)
+
+// batchItem represents summary of a dork in batch search
+type batchItem struct {
+	Dork    string `json:"dork"`
+	Total   uint64 `json:"total"`
+	Fetched int    `json:"fetched"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+func isQuotaError(err error) bool {
+	switch err := err.(type) {
+	case *zoomeye.ErrorResult:
+		return err.Err == "credits_insufficent"
+	case *zoomeye.PartialResultError:
+		for _, v := range err.Pages {
+			if isQuotaError(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// BatchSearch searches the dorks one by one with shared rate limit (requests per second),
+// the matches are tagged with originating dork by "_dork" field and combined into one result,
+// the remaining dorks are skipped if there is no any quota left
+func (a *ZoomEyeAgent) BatchSearch(dorks []string, num int, resource string, force bool, rate int,
+	opts ...zoomeye.SearchOption) (*zoomeye.SearchResult, []*batchItem, error) {
+	if a.zoom == nil {
+		if _, err := a.InitLocal(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if rate > 0 {
+		defer func(zoom *zoomeye.ZoomEye) {
+			a.zoom = zoom
+		}(a.zoom)
+		a.zoom = a.zoom.With(zoomeye.RateLimit(rate, time.Second))
+	}
+	var (
+		combined = &zoomeye.SearchResult{}
+		items    = make([]*batchItem, len(dorks))
+		noQuota  bool
+	)
+	// quota is checked once for the whole batch, the quota errors of searches tell when it runs out later
+	if info, err := a.zoom.ResourcesInfo(); err == nil && info.Resources != nil && info.Resources.Search <= 0 {
+		noQuota = true
+	}
+	for i, dork := range dorks {
+		item := &batchItem{
+			Dork: dork,
+		}
+		items[i] = item
+		if noQuota {
+			item.Status = "skipped"
+			item.Error = "no any quota left"
+			continue
+		}
+		result, err := a.Search(dork, num, resource, force, opts...)
+		noQuota = isQuotaError(err)
+		switch err.(type) {
+		case nil:
+			item.Status = "ok"
+		case *zoomeye.PartialResultError:
+			item.Status = "partial"
+			item.Error = err.Error()
+		default:
+			if item.Status, item.Error = "failed", err.Error(); noQuota {
+				item.Status = "skipped"
+			}
+			continue
+		}
+		item.Total, item.Fetched = result.Total, len(result.Matches)
+		for j, m := range result.Matches {
+			// the matches may be shared with local data, so they are copied before tagging
+			tagged := make(map[string]interface{}, len(m)+1)
+			for k, v := range m {
+				tagged[k] = v
+			}
+			tagged["_dork"] = dork
+			result.Matches[j] = tagged
+		}
+		combined.Extend(result)
+	}
+	var total uint64
+	for _, v := range items {
+		total += v.Total
+	}
+	combined.Total, combined.Facets = total, nil
+	if combined.Type == "" {
+		combined.Type = resource
+	}
+	for _, v := range items {
+		if v.Status == "ok" || v.Status == "partial" {
+			return combined, items, nil
+		}
+	}
+	return combined, items, fmt.Errorf("all of %d dorks failed", len(dorks))
+}
+
+// maxBatchIPs is the max number of IPs expanded from CIDRs in batch history
+const maxBatchIPs = 65536
+
+// expandIPs expands the CIDRs into IPs (network and broadcast addresses of IPv4 are excluded),
+// the duplicate IPs are removed
+func expandIPs(targets []string) ([]string, error) {
+	var (
+		ips  []string
+		seen = make(map[string]struct{})
+		add  = func(ip string) error {
+			if _, ok := seen[ip]; ok {
+				return nil
+			}
+			if len(ips) >= maxBatchIPs {
+				return fmt.Errorf("too many IPs, at most %d IPs are allowed", maxBatchIPs)
+			}
+			seen[ip] = struct{}{}
+			ips = append(ips, ip)
+			return nil
+		}
+	)
+	for _, v := range targets {
+		_, ipNet, err := net.ParseCIDR(v)
+		if err != nil {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid ip address or CIDR: %s", v)
+			}
+			if err = add(ip.String()); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var (
+			ones, bits = ipNet.Mask.Size()
+			ip         = ipNet.IP.Mask(ipNet.Mask)
+		)
+		if bits-ones > 16 {
+			return nil, fmt.Errorf("CIDR %s is too large, at most /%d is allowed", v, bits-16)
+		}
+		for ; ipNet.Contains(ip); ip = nextIP(ip) {
+			if bits == 32 && bits-ones > 1 && (ip.Equal(ipNet.IP) || !ipNet.Contains(nextIP(ip))) {
+				continue
+			}
+			if err = add(ip.String()); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return ips, nil
+}
+
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		if next[i]++; next[i] > 0 {
+			break
+		}
+	}
+	return next
+}
+
+// historyItem represents summary of an IP in batch history
+type historyItem struct {
+	IP     string `json:"ip"`
+	Probes uint64 `json:"probes"`
+	Ports  int    `json:"ports"`
+	Cached bool   `json:"cached"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BatchHistory queries history of the IPs by the specified number of workers,
+// the cached IPs are not queried again unless force is set, records of all IPs are combined into one result
+func (a *ZoomEyeAgent) BatchHistory(ips []string, force bool, workers int, progress func(done, total int)) (*zoomeye.HistoryResult, []*historyItem, error) {
+	if err := a.checkHistoryPlan(); err != nil {
+		return nil, nil, err
+	}
+	if workers <= 0 {
+		workers = 1
+	}
+	var (
+		items   = make([]*historyItem, len(ips))
+		results = make([]*zoomeye.HistoryResult, len(ips))
+		jobs    = make(chan int)
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		done    int
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				item := &historyItem{
+					IP:     ips[i],
+					Status: "ok",
+				}
+				result, cached, err := a.history(ips[i], force)
+				if err != nil {
+					item.Status, item.Error = "failed", err.Error()
+				} else {
+					ports := make(map[string]struct{})
+					for _, v := range result.Data {
+						ports[fmt.Sprintf("%v", v.Find("portinfo.port"))] = struct{}{}
+					}
+					item.Probes, item.Ports, item.Cached = result.Count, len(ports), cached
+					if item.Probes == 0 {
+						item.Status = "empty"
+					}
+					results[i] = result
+				}
+				items[i] = item
+				mu.Lock()
+				if done++; progress != nil {
+					progress(done, len(ips))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range ips {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	var (
+		combined = &zoomeye.HistoryResult{}
+		failed   int
+	)
+	for i, v := range results {
+		if v == nil {
+			failed++
+			continue
+		}
+		for _, d := range v.Data {
+			if _, ok := d["ip"]; !ok {
+				d["ip"] = ips[i]
+			}
+		}
+		combined.Data = append(combined.Data, v.Data...)
+	}
+	combined.Count = uint64(len(combined.Data))
+	if failed == len(ips) {
+		return combined, items, fmt.Errorf("all of %d IPs failed", len(ips))
+	}
+	return combined, items, nil
+}
@@ -0,0 +1,480 @@
+package main
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	chartWidth   = 800
+	chartTop     = 40
+	chartPadding = 20
+	chartRow     = 22
+	charWidth    = 12
+	charHeight   = 14
+)
+
+var (
+	chartColors = []color.RGBA{
+		{0x3b, 0x82, 0xf6, 0xff}, {0xa8, 0x55, 0xf7, 0xff}, {0x06, 0xb6, 0xd4, 0xff}, {0xef, 0x44, 0x44, 0xff},
+		{0xea, 0xb3, 0x08, 0xff}, {0x60, 0xa5, 0xfa, 0xff}, {0xc0, 0x84, 0xfc, 0xff}, {0x22, 0xd3, 0xee, 0xff},
+		{0xf8, 0x71, 0x71, 0xff}, {0xfa, 0xcc, 0x15, 0xff},
+	}
+	chartBackground = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	chartText       = color.RGBA{0x33, 0x33, 0x33, 0xff}
+	chartGrid       = color.RGBA{0xe5, 0xe7, 0xeb, 0xff}
+)
+
+// glyphs is a 5x7 bitmap font of printable ASCII characters for PNG charts
+var glyphs = [95][7]byte{
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, // space
+	{0x04, 0x04, 0x04, 0x04, 0x04, 0x00, 0x04}, // !
+	{0x0A, 0x0A, 0x00, 0x00, 0x00, 0x00, 0x00}, // "
+	{0x0A, 0x1F, 0x0A, 0x0A, 0x0A, 0x1F, 0x0A}, // #
+	{0x04, 0x0F, 0x14, 0x0E, 0x05, 0x1E, 0x04}, // $
+	{0x18, 0x19, 0x02, 0x04, 0x08, 0x13, 0x03}, // %
+	{0x0C, 0x12, 0x14, 0x08, 0x15, 0x12, 0x0D}, // &
+	{0x04, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00}, // '
+	{0x02, 0x04, 0x08, 0x08, 0x08, 0x04, 0x02}, // (
+	{0x08, 0x04, 0x02, 0x02, 0x02, 0x04, 0x08}, // )
+	{0x00, 0x04, 0x15, 0x0E, 0x15, 0x04, 0x00}, // *
+	{0x00, 0x04, 0x04, 0x1F, 0x04, 0x04, 0x00}, // +
+	{0x00, 0x00, 0x00, 0x00, 0x06, 0x04, 0x08}, // ,
+	{0x00, 0x00, 0x00, 0x1F, 0x00, 0x00, 0x00}, // -
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x0C, 0x0C}, // .
+	{0x00, 0x01, 0x02, 0x04, 0x08, 0x10, 0x00}, // /
+	{0x0E, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0E}, // 0
+	{0x04, 0x0C, 0x04, 0x04, 0x04, 0x04, 0x0E}, // 1
+	{0x0E, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1F}, // 2
+	{0x1F, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0E}, // 3
+	{0x02, 0x06, 0x0A, 0x12, 0x1F, 0x02, 0x02}, // 4
+	{0x1F, 0x10, 0x1E, 0x01, 0x01, 0x11, 0x0E}, // 5
+	{0x06, 0x08, 0x10, 0x1E, 0x11, 0x11, 0x0E}, // 6
+	{0x1F, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08}, // 7
+	{0x0E, 0x11, 0x11, 0x0E, 0x11, 0x11, 0x0E}, // 8
+	{0x0E, 0x11, 0x11, 0x0F, 0x01, 0x02, 0x0C}, // 9
+	{0x00, 0x0C, 0x0C, 0x00, 0x0C, 0x0C, 0x00}, // :
+	{0x00, 0x0C, 0x0C, 0x00, 0x0C, 0x04, 0x08}, // ;
+	{0x02, 0x04, 0x08, 0x10, 0x08, 0x04, 0x02}, // <
+	{0x00, 0x00, 0x1F, 0x00, 0x1F, 0x00, 0x00}, // =
+	{0x08, 0x04, 0x02, 0x01, 0x02, 0x04, 0x08}, // >
+	{0x0E, 0x11, 0x01, 0x02, 0x04, 0x00, 0x04}, // ?
+	{0x0E, 0x11, 0x01, 0x0D, 0x15, 0x15, 0x0E}, // @
+	{0x0E, 0x11, 0x11, 0x1F, 0x11, 0x11, 0x11}, // A
+	{0x1E, 0x11, 0x11, 0x1E, 0x11, 0x11, 0x1E}, // B
+	{0x0E, 0x11, 0x10, 0x10, 0x10, 0x11, 0x0E}, // C
+	{0x1C, 0x12, 0x11, 0x11, 0x11, 0x12, 0x1C}, // D
+	{0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x1F}, // E
+	{0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x10}, // F
+	{0x0E, 0x11, 0x10, 0x17, 0x11, 0x11, 0x0F}, // G
+	{0x11, 0x11, 0x11, 0x1F, 0x11, 0x11, 0x11}, // H
+	{0x0E, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0E}, // I
+	{0x07, 0x02, 0x02, 0x02, 0x02, 0x12, 0x0C}, // J
+	{0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11}, // K
+	{0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x1F}, // L
+	{0x11, 0x1B, 0x15, 0x15, 0x11, 0x11, 0x11}, // M
+	{0x11, 0x11, 0x19, 0x15, 0x13, 0x11, 0x11}, // N
+	{0x0E, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E}, // O
+	{0x1E, 0x11, 0x11, 0x1E, 0x10, 0x10, 0x10}, // P
+	{0x0E, 0x11, 0x11, 0x11, 0x15, 0x12, 0x0D}, // Q
+	{0x1E, 0x11, 0x11, 0x1E, 0x14, 0x12, 0x11}, // R
+	{0x0F, 0x10, 0x10, 0x0E, 0x01, 0x01, 0x1E}, // S
+	{0x1F, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04}, // T
+	{0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E}, // U
+	{0x11, 0x11, 0x11, 0x11, 0x11, 0x0A, 0x04}, // V
+	{0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0A}, // W
+	{0x11, 0x11, 0x0A, 0x04, 0x0A, 0x11, 0x11}, // X
+	{0x11, 0x11, 0x11, 0x0A, 0x04, 0x04, 0x04}, // Y
+	{0x1F, 0x01, 0x02, 0x04, 0x08, 0x10, 0x1F}, // Z
+	{0x0E, 0x08, 0x08, 0x08, 0x08, 0x08, 0x0E}, // [
+	{0x00, 0x10, 0x08, 0x04, 0x02, 0x01, 0x00}, // \
+	{0x0E, 0x02, 0x02, 0x02, 0x02, 0x02, 0x0E}, // ]
+	{0x04, 0x0A, 0x11, 0x00, 0x00, 0x00, 0x00}, // ^
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x1F}, // _
+	{0x08, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00}, // `
+	{0x00, 0x00, 0x0E, 0x01, 0x0F, 0x11, 0x0F}, // a
+	{0x10, 0x10, 0x16, 0x19, 0x11, 0x11, 0x1E}, // b
+	{0x00, 0x00, 0x0E, 0x10, 0x10, 0x11, 0x0E}, // c
+	{0x01, 0x01, 0x0D, 0x13, 0x11, 0x11, 0x0F}, // d
+	{0x00, 0x00, 0x0E, 0x11, 0x1F, 0x10, 0x0E}, // e
+	{0x06, 0x09, 0x08, 0x1C, 0x08, 0x08, 0x08}, // f
+	{0x00, 0x0F, 0x11, 0x11, 0x0F, 0x01, 0x0E}, // g
+	{0x10, 0x10, 0x16, 0x19, 0x11, 0x11, 0x11}, // h
+	{0x04, 0x00, 0x0C, 0x04, 0x04, 0x04, 0x0E}, // i
+	{0x02, 0x00, 0x06, 0x02, 0x02, 0x12, 0x0C}, // j
+	{0x10, 0x10, 0x12, 0x14, 0x18, 0x14, 0x12}, // k
+	{0x0C, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0E}, // l
+	{0x00, 0x00, 0x1A, 0x15, 0x15, 0x11, 0x11}, // m
+	{0x00, 0x00, 0x16, 0x19, 0x11, 0x11, 0x11}, // n
+	{0x00, 0x00, 0x0E, 0x11, 0x11, 0x11, 0x0E}, // o
+	{0x00, 0x00, 0x1E, 0x11, 0x1E, 0x10, 0x10}, // p
+	{0x00, 0x00, 0x0D, 0x13, 0x0F, 0x01, 0x01}, // q
+	{0x00, 0x00, 0x16, 0x19, 0x10, 0x10, 0x10}, // r
+	{0x00, 0x00, 0x0E, 0x10, 0x0E, 0x01, 0x1E}, // s
+	{0x08, 0x08, 0x1C, 0x08, 0x08, 0x09, 0x06}, // t
+	{0x00, 0x00, 0x11, 0x11, 0x11, 0x13, 0x0D}, // u
+	{0x00, 0x00, 0x11, 0x11, 0x11, 0x0A, 0x04}, // v
+	{0x00, 0x00, 0x11, 0x11, 0x15, 0x15, 0x0A}, // w
+	{0x00, 0x00, 0x11, 0x0A, 0x04, 0x0A, 0x11}, // x
+	{0x00, 0x00, 0x11, 0x11, 0x0F, 0x01, 0x0E}, // y
+	{0x00, 0x00, 0x1F, 0x02, 0x04, 0x08, 0x1F}, // z
+	{0x02, 0x04, 0x04, 0x08, 0x04, 0x04, 0x02}, // {
+	{0x04, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04}, // |
+	{0x08, 0x04, 0x04, 0x02, 0x04, 0x04, 0x08}, // }
+	{0x00, 0x00, 0x08, 0x15, 0x02, 0x00, 0x00}, // ~
+}
+
+// chartItem represents a slice or bar of chart
+type chartItem struct {
+	Name  string
+	Count uint64
+	Ratio float64
+}
+
+// chartSeries represents data of a facet or statistics field
+type chartSeries struct {
+	Title string
+	Items []*chartItem
+}
+
+func newChartSeries(title string, body map[string][][]interface{}) []*chartSeries {
+	keys := make([]string, 0, len(body))
+	for k := range body {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	series := make([]*chartSeries, 0, len(keys))
+	for _, k := range keys {
+		s := &chartSeries{
+			Title: title + ": " + k,
+		}
+		for _, v := range body[k] {
+			s.Items = append(s.Items, &chartItem{
+				Name:  toStr(v[0]),
+				Count: v[1].(uint64),
+				Ratio: v[2].(float64),
+			})
+		}
+		series = append(series, s)
+	}
+	return series
+}
+
+// top returns the top n items, the others are merged into one item
+func (s *chartSeries) top(n int) []*chartItem {
+	if len(s.Items) <= n {
+		return s.Items
+	}
+	others := &chartItem{
+		Name: "Others",
+	}
+	for _, v := range s.Items[n-1:] {
+		others.Count += v.Count
+		others.Ratio += v.Ratio
+	}
+	return append(append([]*chartItem{}, s.Items[:n-1]...), others)
+}
+
+type canvas interface {
+	rect(x, y, w, h int, c color.RGBA)
+	wedge(cx, cy, r int, from, to float64, c color.RGBA)
+	text(x, y int, s string, c color.RGBA, anchor int)
+}
+
+const (
+	anchorStart = iota
+	anchorMiddle
+	anchorEnd
+)
+
+func textWidth(s string) int {
+	return len([]rune(s)) * charWidth
+}
+
+func shortStr(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-2]) + ".."
+	}
+	return s
+}
+
+// svgCanvas draws chart into SVG document
+type svgCanvas struct {
+	builder strings.Builder
+}
+
+func svgColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func (c *svgCanvas) rect(x, y, w, h int, fill color.RGBA) {
+	fmt.Fprintf(&c.builder, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`+"\n", x, y, w, h, svgColor(fill))
+}
+
+func (c *svgCanvas) wedge(cx, cy, r int, from, to float64, fill color.RGBA) {
+	if to-from >= 2*math.Pi-1e-9 {
+		fmt.Fprintf(&c.builder, `<circle cx="%d" cy="%d" r="%d" fill="%s"/>`+"\n", cx, cy, r, svgColor(fill))
+		return
+	}
+	var (
+		x0, y0 = float64(cx) + float64(r)*math.Sin(from), float64(cy) - float64(r)*math.Cos(from)
+		x1, y1 = float64(cx) + float64(r)*math.Sin(to), float64(cy) - float64(r)*math.Cos(to)
+		large  int
+	)
+	if to-from > math.Pi {
+		large = 1
+	}
+	fmt.Fprintf(&c.builder, `<path d="M%d,%d L%.2f,%.2f A%d,%d 0 %d 1 %.2f,%.2f Z" fill="%s" stroke="#ffffff"/>`+"\n",
+		cx, cy, x0, y0, r, r, large, x1, y1, svgColor(fill))
+}
+
+func (c *svgCanvas) text(x, y int, s string, fill color.RGBA, anchor int) {
+	a := [...]string{"start", "middle", "end"}[anchor]
+	c.builder.WriteString(fmt.Sprintf(`<text x="%d" y="%d" fill="%s" text-anchor="%s">`, x, y+charHeight-2, svgColor(fill), a))
+	xml.EscapeText(&c.builder, []byte(s))
+	c.builder.WriteString("</text>\n")
+}
+
+func (c *svgCanvas) encode(w *bufio.Writer, width, height int) error {
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" `+
+		`font-family="monospace" font-size="%d">`+"\n", width, height, width, height, charWidth*5/3)
+	fmt.Fprintf(w, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", svgColor(chartBackground))
+	w.WriteString(c.builder.String())
+	w.WriteString("</svg>\n")
+	return w.Flush()
+}
+
+// pngCanvas draws chart into RGBA image
+type pngCanvas struct {
+	img *image.RGBA
+}
+
+func (c *pngCanvas) rect(x, y, w, h int, fill color.RGBA) {
+	draw.Draw(c.img, image.Rect(x, y, x+w, y+h), image.NewUniform(fill), image.Point{}, draw.Src)
+}
+
+func (c *pngCanvas) wedge(cx, cy, r int, from, to float64, fill color.RGBA) {
+	for y := cy - r; y <= cy+r; y++ {
+		for x := cx - r; x <= cx+r; x++ {
+			dx, dy := float64(x-cx), float64(y-cy)
+			if dx*dx+dy*dy > float64(r*r) {
+				continue
+			}
+			a := math.Atan2(dx, -dy)
+			if a < 0 {
+				a += 2 * math.Pi
+			}
+			if a >= from && a < to {
+				c.img.SetRGBA(x, y, fill)
+			}
+		}
+	}
+}
+
+func (c *pngCanvas) text(x, y int, s string, fill color.RGBA, anchor int) {
+	switch anchor {
+	case anchorMiddle:
+		x -= textWidth(s) / 2
+	case anchorEnd:
+		x -= textWidth(s)
+	}
+	for _, r := range s {
+		if r < 32 || r > 126 {
+			r = '?'
+		}
+		for row, bits := range glyphs[r-32] {
+			for col := 0; col < 5; col++ {
+				if bits&(0x10>>uint(col)) != 0 {
+					c.rect(x+1+col*2, y+row*2, 2, 2, fill)
+				}
+			}
+		}
+		x += charWidth
+	}
+}
+
+func chartLabel(v *chartItem) string {
+	return fmt.Sprintf("%s  %d (%.2f%%)", shortStr(v.Name, 30), v.Count, v.Ratio*100)
+}
+
+func panelHeight(kind string, s *chartSeries) int {
+	n := len(s.top(10))
+	switch kind {
+	case "pie":
+		h := n * chartRow
+		if h < 240 {
+			h = 240
+		}
+		return chartTop + h + chartPadding
+	case "stack":
+		return chartTop + 30 + chartPadding + (n+1)/2*chartRow + chartPadding
+	default:
+		if len(s.Items) > 20 {
+			n = 20
+		} else {
+			n = len(s.Items)
+		}
+		return chartTop + n*chartRow + chartPadding
+	}
+}
+
+func drawPanel(c canvas, kind string, s *chartSeries, y int) {
+	c.text(chartPadding, y+10, s.Title, chartText, anchorStart)
+	c.rect(chartPadding, y+30, chartWidth-2*chartPadding, 1, chartGrid)
+	y += chartTop
+	switch kind {
+	case "pie":
+		var (
+			items = s.top(10)
+			total float64
+			from  float64
+		)
+		for _, v := range items {
+			total += float64(v.Count)
+		}
+		for i, v := range items {
+			to := from
+			if total > 0 {
+				to += float64(v.Count) / total * 2 * math.Pi
+			}
+			if i == len(items)-1 && total > 0 {
+				to = 2 * math.Pi
+			}
+			clr := chartColors[i%len(chartColors)]
+			if to > from {
+				c.wedge(150, y+120, 110, from, to, clr)
+			}
+			c.rect(300, y+i*chartRow+2, 12, 12, clr)
+			c.text(320, y+i*chartRow, chartLabel(v), chartText, anchorStart)
+			from = to
+		}
+	case "stack":
+		var (
+			items = s.top(10)
+			total float64
+			x     = chartPadding
+			width = chartWidth - 2*chartPadding
+		)
+		for _, v := range items {
+			total += float64(v.Count)
+		}
+		for i, v := range items {
+			var w int
+			if total > 0 {
+				w = int(math.Round(float64(v.Count) / total * float64(width)))
+				if i == len(items)-1 {
+					w = chartPadding + width - x
+				}
+			}
+			clr := chartColors[i%len(chartColors)]
+			if w > 0 {
+				c.rect(x, y, w, 30, clr)
+			}
+			x += w
+			var (
+				lx = chartPadding + i%2*(width/2)
+				ly = y + 30 + chartPadding + i/2*chartRow
+			)
+			c.rect(lx, ly+2, 12, 12, clr)
+			c.text(lx+20, ly, chartLabel(v), chartText, anchorStart)
+		}
+	default:
+		var (
+			items = s.Items
+			max   uint64
+		)
+		if len(items) > 20 {
+			items = items[:20]
+		}
+		for _, v := range items {
+			if v.Count > max {
+				max = v.Count
+			}
+		}
+		for i, v := range items {
+			var (
+				ry = y + i*chartRow
+				w  int
+			)
+			if max > 0 {
+				w = int(float64(v.Count) / float64(max) * 360)
+			}
+			if w < 1 {
+				w = 1
+			}
+			c.text(250, ry, shortStr(v.Name, 19), chartText, anchorEnd)
+			c.rect(260, ry+1, w, chartRow-6, chartColors[i%len(chartColors)])
+			c.text(268+w, ry, fmt.Sprintf("%d (%.2f%%)", v.Count, v.Ratio*100), chartText, anchorStart)
+		}
+	}
+}
+
+func chartHeight(kind string, series []*chartSeries) int {
+	height := chartPadding
+	for _, s := range series {
+		height += panelHeight(kind, s)
+	}
+	return height
+}
+
+func drawChart(c canvas, kind string, series []*chartSeries) {
+	y := chartPadding
+	for _, s := range series {
+		drawPanel(c, kind, s, y)
+		y += panelHeight(kind, s)
+	}
+}
+
+// chartSVG draws the series into SVG document which can be inlined into HTML
+func chartSVG(kind string, series []*chartSeries) string {
+	var (
+		c       = &svgCanvas{}
+		builder strings.Builder
+	)
+	drawChart(c, kind, series)
+	c.encode(bufio.NewWriter(&builder), chartWidth, chartHeight(kind, series))
+	return builder.String()
+}
+
+// renderChart draws the series into SVG or PNG file by extension of path,
+// kind is one of pie, stack and hist (horizontal bar)
+func renderChart(path, kind string, series []*chartSeries) error {
+	if len(series) == 0 {
+		return fmt.Errorf("no any facet or statistics data")
+	}
+	var (
+		ext    = strings.ToLower(filepath.Ext(path))
+		height = chartHeight(kind, series)
+		c      canvas
+	)
+	switch ext {
+	case ".svg":
+		c = &svgCanvas{}
+	case ".png":
+		img := image.NewRGBA(image.Rect(0, 0, chartWidth, height))
+		draw.Draw(img, img.Bounds(), image.NewUniform(chartBackground), image.Point{}, draw.Src)
+		c = &pngCanvas{img}
+	default:
+		return fmt.Errorf("unsupported figure format %s, only svg and png are supported", ext)
+	}
+	drawChart(c, kind, series)
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if svg, ok := c.(*svgCanvas); ok {
+		return svg.encode(bufio.NewWriter(f), chartWidth, height)
+	}
+	return png.Encode(f, c.(*pngCanvas).img)
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gyyyy/ZoomEye-go/zoomeye"
+)
+
+var (
+	versionRegexp = regexp.MustCompile(`\d+(?:\.\d+)+[a-z]?`)
+	headerRegexp  = regexp.MustCompile(`^([A-Za-z0-9-]+):\s*(.*)$`)
+)
+
+// diffLine represents a line of diff, op is one of ' ', '-' and '+'
+type diffLine struct {
+	Op   byte
+	Text string
+}
+
+// diffHunk represents a hunk of unified diff
+type diffHunk struct {
+	AStart, ALen int
+	BStart, BLen int
+	Lines        []*diffLine
+}
+
+func (h *diffHunk) String() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.AStart, h.ALen, h.BStart, h.BLen)
+}
+
+// diffLines compares lines of a and b by longest common subsequence
+func diffLines(a, b []string) []*diffLine {
+	var (
+		n, m = len(a), len(b)
+		lcs  = make([][]int, n+1)
+	)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var (
+		lines = make([]*diffLine, 0, n+m)
+		i, j  int
+	)
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, &diffLine{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, &diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, &diffLine{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		lines = append(lines, &diffLine{'-', a[i]})
+	}
+	for ; j < m; j++ {
+		lines = append(lines, &diffLine{'+', b[j]})
+	}
+	return lines
+}
+
+// unifiedHunks groups the changed lines into hunks with the specified number of context lines
+func unifiedHunks(lines []*diffLine, context int) []*diffHunk {
+	var (
+		hunks      []*diffHunk
+		aPos, bPos = make([]int, len(lines)+1), make([]int, len(lines)+1)
+		changed    []int
+	)
+	for i, v := range lines {
+		aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+		if v.Op != '+' {
+			aPos[i+1]++
+		}
+		if v.Op != '-' {
+			bPos[i+1]++
+		}
+		if v.Op != ' ' {
+			changed = append(changed, i)
+		}
+	}
+	for k := 0; k < len(changed); {
+		end := k
+		for end+1 < len(changed) && changed[end+1]-changed[end] <= 2*context+1 {
+			end++
+		}
+		var (
+			from = changed[k] - context
+			to   = changed[end] + context + 1
+		)
+		if from < 0 {
+			from = 0
+		}
+		if to > len(lines) {
+			to = len(lines)
+		}
+		hunk := &diffHunk{
+			AStart: aPos[from],
+			ALen:   aPos[to] - aPos[from],
+			BStart: bPos[from],
+			BLen:   bPos[to] - bPos[from],
+			Lines:  lines[from:to],
+		}
+		if hunk.ALen > 0 {
+			hunk.AStart++
+		}
+		if hunk.BLen > 0 {
+			hunk.BStart++
+		}
+		hunks = append(hunks, hunk)
+		k = end + 1
+	}
+	return hunks
+}
+
+func splitBanner(s string) []string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func bannerHeaders(lines []string) map[string]string {
+	headers := make(map[string]string)
+	for _, v := range lines {
+		if v == "" {
+			break
+		}
+		if sub := headerRegexp.FindStringSubmatch(v); sub != nil {
+			headers[strings.ToLower(sub[1])] = sub[1] + ": " + sub[2]
+		}
+	}
+	return headers
+}
+
+// headerChanges compares the header lines (before the first empty line) of banners
+func headerChanges(a, b []string) []string {
+	var (
+		ha, hb  = bannerHeaders(a), bannerHeaders(b)
+		changes []string
+	)
+	for k, v := range ha {
+		if nv, ok := hb[k]; !ok {
+			changes = append(changes, "- "+v)
+		} else if nv != v {
+			changes = append(changes, "~ "+v+" => "+strings.TrimSpace(strings.SplitN(nv, ":", 2)[1]))
+		}
+	}
+	for k, v := range hb {
+		if _, ok := ha[k]; !ok {
+			changes = append(changes, "+ "+v)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i][2:] < changes[j][2:]
+	})
+	return changes
+}
+
+// bannerDiff represents change of banner between two consecutive probes of a port
+type bannerDiff struct {
+	Port     string
+	Service  string
+	From, To string
+	FromApp  string
+	ToApp    string
+	Hunks    []*diffHunk
+	Headers  []string
+}
+
+func probeApp(m interface{ FindString(string) string }) string {
+	s := strings.TrimSpace(m.FindString("portinfo.product") + " " + m.FindString("portinfo.version"))
+	if s == "" {
+		return "[unknown]"
+	}
+	return s
+}
+
+// bannerDiffs walks history of the port (all ports if it is 0) in time order,
+// and compares raw_data of each probe with the previous one
+func bannerDiffs(result *zoomeye.HistoryResult, port int) ([]*bannerDiff, int) {
+	var (
+		groups = make(map[string][]int)
+		keys   []string
+	)
+	for i, v := range result.Data {
+		p := v.FindString("portinfo.port")
+		if port > 0 && p != fmt.Sprintf("%d", port) {
+			continue
+		}
+		if _, ok := groups[p]; !ok {
+			keys = append(keys, p)
+		}
+		groups[p] = append(groups[p], i)
+	}
+	sort.Strings(keys)
+	var (
+		diffs  []*bannerDiff
+		probes int
+	)
+	for _, k := range keys {
+		indexes := groups[k]
+		sort.SliceStable(indexes, func(i, j int) bool {
+			return result.Data[indexes[i]].FindString("timestamp") < result.Data[indexes[j]].FindString("timestamp")
+		})
+		probes += len(indexes)
+		for i := 1; i < len(indexes); i++ {
+			var (
+				prev = result.Data[indexes[i-1]]
+				curr = result.Data[indexes[i]]
+				a    = splitBanner(prev.FindString("raw_data"))
+				b    = splitBanner(curr.FindString("raw_data"))
+			)
+			hunks := unifiedHunks(diffLines(a, b), 3)
+			if len(hunks) == 0 {
+				continue
+			}
+			diffs = append(diffs, &bannerDiff{
+				Port:    k,
+				Service: curr.FindString("portinfo.service"),
+				From:    prev.FindString("timestamp"),
+				To:      curr.FindString("timestamp"),
+				FromApp: probeApp(prev),
+				ToApp:   probeApp(curr),
+				Hunks:   hunks,
+				Headers: headerChanges(a, b),
+			})
+		}
+	}
+	return diffs, probes
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gyyyy/ZoomEye-go/zoomeye"
+)
+
+const (
+	// defaultBulkSize is the default number of documents in each bulk request
+	defaultBulkSize = 500
+	// defaultBulkRetry is the default number of retries of rejected documents
+	defaultBulkRetry = 3
+)
+
+// esBackoff is the initial delay before retrying rejected documents, it is doubled on each retry
+var esBackoff = time.Second
+
+var (
+	esKeyword = map[string]interface{}{"type": "keyword"}
+	esText    = map[string]interface{}{"type": "text"}
+	esProduct = map[string]interface{}{
+		"properties": map[string]interface{}{
+			"name":    esKeyword,
+			"version": esKeyword,
+		},
+	}
+	esNames = map[string]interface{}{
+		"properties": map[string]interface{}{
+			"en": esKeyword,
+		},
+	}
+)
+
+// esMapping returns mapping of both host and web matches, location is geo_point from geoinfo.location
+func esMapping() map[string]interface{} {
+	return map[string]interface{}{
+		"properties": map[string]interface{}{
+			"@timestamp":   map[string]interface{}{"type": "date"},
+			"ip":           map[string]interface{}{"type": "ip"},
+			"location":     map[string]interface{}{"type": "geo_point"},
+			"zoomeye_dork": esKeyword,
+			"zoomeye_type": esKeyword,
+			"portinfo": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"app":      esKeyword,
+					"version":  esKeyword,
+					"service":  esKeyword,
+					"port":     map[string]interface{}{"type": "integer"},
+					"os":       esKeyword,
+					"device":   esKeyword,
+					"hostname": esKeyword,
+					"banner":   esText,
+				},
+			},
+			"geoinfo": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"country": map[string]interface{}{
+						"properties": map[string]interface{}{
+							"code":  esKeyword,
+							"names": esNames,
+						},
+					},
+					"city": map[string]interface{}{
+						"properties": map[string]interface{}{
+							"names": esNames,
+						},
+					},
+					"asn":          esKeyword,
+					"organization": esKeyword,
+				},
+			},
+			"site":      esKeyword,
+			"domains":   esKeyword,
+			"title":     esText,
+			"keywords":  esText,
+			"headers":   esText,
+			"webapp":    esProduct,
+			"server":    esProduct,
+			"framework": esProduct,
+			"component": esProduct,
+			"frontend":  esProduct,
+			"waf":       esProduct,
+			"db":        esProduct,
+			"system":    esProduct,
+		},
+	}
+}
+
+// esDoc represents a document of bulk request, ID is ip:port (host) or site (web)
+// so that exporting the same results again updates the documents
+type esDoc struct {
+	ID     string
+	Source map[string]interface{}
+}
+
+func esDocs(result *zoomeye.SearchResult, dork string) []*esDoc {
+	docs := make([]*esDoc, 0, len(result.Matches))
+	for _, m := range result.Matches {
+		src := make(map[string]interface{}, len(m)+4)
+		for k, v := range m {
+			if !strings.HasPrefix(k, "_") {
+				src[k] = v
+			}
+		}
+		d := dork
+		if s := m.FindString("_dork"); s != "" {
+			d = s
+		}
+		src["zoomeye_dork"] = d
+		src["zoomeye_type"] = result.Type
+		if t := m.FindString("timestamp"); t != "" {
+			src["@timestamp"] = t
+		}
+		lat, lon := m.Find("geoinfo.location.lat"), m.Find("geoinfo.location.lon")
+		if lat != nil && lon != nil {
+			src["location"] = map[string]interface{}{"lat": lat, "lon": lon}
+		}
+		docs = append(docs, &esDoc{
+			ID:     matchKey(result.Type, m),
+			Source: src,
+		})
+	}
+	return docs
+}
+
+func bulkBody(index string, docs []*esDoc) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, d := range docs {
+		action := map[string]interface{}{
+			"index": map[string]interface{}{"_index": index, "_id": d.ID},
+		}
+		for _, o := range []interface{}{action, d.Source} {
+			b, err := json.Marshal(o)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(b)
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// writeBulkNDJSON writes the documents as body of bulk API into the file instead of sending them
+func writeBulkNDJSON(path, index string, docs []*esDoc) error {
+	b, err := bulkBody(index, docs)
+	if err != nil {
+		return err
+	}
+	return writeFile(path, b)
+}
+
+// esStats represents result of exporting documents
+type esStats struct {
+	Indexed int
+	Failed  int
+	Retried int
+	Errors  map[string]int
+}
+
+// esExporter sends documents to Elasticsearch or OpenSearch by bulk API
+type esExporter struct {
+	base  *url.URL
+	index string
+	size  int
+	retry int
+	cli   *http.Client
+}
+
+func newESExporter(rawurl, index string, size, retry int) (*esExporter, error) {
+	u, err := url.Parse(strings.TrimRight(rawurl, "/"))
+	if err != nil {
+		return nil, err
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid Elasticsearch URL %s", rawurl)
+	}
+	if index = strings.ToLower(strings.TrimSpace(index)); index == "" {
+		return nil, fmt.Errorf("index name missing")
+	}
+	if size <= 0 {
+		size = defaultBulkSize
+	}
+	if retry < 0 {
+		retry = 0
+	}
+	return &esExporter{
+		base:  u,
+		index: index,
+		size:  size,
+		retry: retry,
+		cli: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+	}, nil
+}
+
+func (e *esExporter) do(method, path, contentType string, body []byte) ([]byte, int, error) {
+	u := *e.base
+	u.User = nil
+	u.Path += path
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if user := e.base.User; user != nil {
+		pass, _ := user.Password()
+		req.SetBasicAuth(user.Username(), pass)
+	}
+	resp, err := e.cli.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	return b, resp.StatusCode, err
+}
+
+// PutTemplate creates (or updates) the index template whose pattern is the index name
+func (e *esExporter) PutTemplate() error {
+	b, _ := json.Marshal(map[string]interface{}{
+		"index_patterns": []string{e.index},
+		"template": map[string]interface{}{
+			"mappings": esMapping(),
+		},
+	})
+	resp, code, err := e.do(http.MethodPut, "/_index_template/"+url.PathEscape(e.index), "application/json", b)
+	if err != nil {
+		return err
+	} else if code/100 != 2 {
+		return fmt.Errorf("failed to put index template (%d): %s", code, shortStr(escapeCtrl(string(resp)), 200))
+	}
+	return nil
+}
+
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  *struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// bulk sends the documents and returns the ones which should be retried (429 and 5xx)
+func (e *esExporter) bulk(docs []*esDoc, stats *esStats) ([]*esDoc, error) {
+	body, err := bulkBody(e.index, docs)
+	if err != nil {
+		return nil, err
+	}
+	resp, code, err := e.do(http.MethodPost, "/_bulk", "application/x-ndjson", body)
+	if err != nil || code == http.StatusTooManyRequests || code >= 500 {
+		return docs, err
+	} else if code/100 != 2 {
+		return nil, fmt.Errorf("bulk request is rejected (%d): %s", code, shortStr(escapeCtrl(string(resp)), 200))
+	}
+	var result bulkResponse
+	if err = json.Unmarshal(resp, &result); err != nil {
+		return nil, err
+	}
+	var retry []*esDoc
+	for i, item := range result.Items {
+		if i >= len(docs) {
+			break
+		}
+		for _, v := range item {
+			switch {
+			case v.Status/100 == 2:
+				stats.Indexed++
+			case v.Status == http.StatusTooManyRequests || v.Status >= 500:
+				retry = append(retry, docs[i])
+			default:
+				stats.Failed++
+				reason := fmt.Sprintf("status %d", v.Status)
+				if v.Error != nil {
+					reason = v.Error.Type + ": " + v.Error.Reason
+				}
+				stats.Errors[reason]++
+			}
+		}
+	}
+	return retry, nil
+}
+
+// Export sends the documents in batches, the rejected documents are retried with exponential backoff
+func (e *esExporter) Export(docs []*esDoc, progress func(done, total int)) (*esStats, error) {
+	stats := &esStats{
+		Errors: make(map[string]int),
+	}
+	for i := 0; i < len(docs); i += e.size {
+		var (
+			end     = i + e.size
+			pending []*esDoc
+			err     error
+		)
+		if end > len(docs) {
+			end = len(docs)
+		}
+		pending = docs[i:end]
+		for n, delay := 0, esBackoff; len(pending) > 0; n, delay = n+1, delay*2 {
+			if n > 0 {
+				if n > e.retry {
+					stats.Failed += len(pending)
+					if err != nil {
+						stats.Errors[err.Error()] += len(pending)
+					} else {
+						stats.Errors["too many retries"] += len(pending)
+					}
+					break
+				}
+				stats.Retried += len(pending)
+				time.Sleep(delay)
+			}
+			if pending, err = e.bulk(pending, stats); err != nil && pending == nil {
+				return stats, err
+			}
+		}
+		if progress != nil {
+			progress(end, len(docs))
+		}
+	}
+	return stats, nil
+}
+
+func loadDocs(agent *ZoomEyeAgent, files []string) (map[string][]*esDoc, error) {
+	docs := make(map[string][]*esDoc)
+	for _, file := range files {
+		result, err := agent.Load(file)
+		if err != nil {
+			return nil, fmt.Errorf("invalid local data %s: %v", file, err)
+		}
+		docs[result.Type] = append(docs[result.Type], esDocs(result, dorkOfFile(file))...)
+	}
+	return docs, nil
+}
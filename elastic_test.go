@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gyyyy/ZoomEye-go/zoomeye"
+)
+
+func TestElasticExport(t *testing.T) {
+	var (
+		template map[string]interface{}
+		bulks    int
+		lines    int
+	)
+	es := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "elastic" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/_index_template/zoomeye-hosts":
+			json.Unmarshal(b, &template)
+			w.Write([]byte(`{"acknowledged":true}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/_bulk":
+			bulks++
+			var (
+				docs  = strings.Split(strings.TrimSpace(string(b)), "\n")
+				items []string
+			)
+			lines += len(docs)
+			for i := 0; i < len(docs)/2; i++ {
+				switch {
+				case bulks == 1 && i == 0:
+					items = append(items, `{"index":{"status":429,"error":{"type":"es_rejected_execution_exception","reason":"queue full"}}}`)
+				case strings.Contains(docs[i*2+1], `"10.0.0.6"`):
+					items = append(items, `{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"bad ip"}}}`)
+				default:
+					items = append(items, `{"index":{"status":201}}`)
+				}
+			}
+			w.Write([]byte(`{"errors":true,"items":[` + strings.Join(items, ",") + `]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer es.Close()
+	result := &zoomeye.SearchResult{Type: "host"}
+	if err := json.Unmarshal([]byte(`{"total": 5, "matches": [
+		{"ip": "10.0.0.1", "portinfo": {"port": 80}, "geoinfo": {"location": {"lat": 39.9, "lon": 116.4}}},
+		{"ip": "10.0.0.6", "portinfo": {"port": 80}},
+		{"ip": "10.0.0.11", "portinfo": {"port": 80}},
+		{"ip": "10.0.0.16", "portinfo": {"port": 443}},
+		{"ip": "10.0.0.21", "portinfo": {"port": 8080}}
+	]}`), result); err != nil {
+		t.FailNow()
+	}
+	docs := esDocs(result, "nginx")
+	if docs[0].ID != "10.0.0.1:80" || docs[1].ID != "10.0.0.6:80" || docs[0].Source["zoomeye_dork"] != "nginx" || docs[0].Source["location"] == nil {
+		t.FailNow()
+	}
+	defer func(backoff time.Duration) {
+		esBackoff = backoff
+	}(esBackoff)
+	esBackoff = time.Millisecond
+	exporter, err := newESExporter(strings.Replace(es.URL, "http://", "http://elastic:secret@", 1), "zoomeye-hosts", 2, 2)
+	if err != nil || exporter.PutTemplate() != nil || template["index_patterns"] == nil {
+		t.FailNow()
+	}
+	stats, err := exporter.Export(docs, nil)
+	if err != nil || stats.Indexed != 4 || stats.Failed != 1 || stats.Retried != 1 || bulks != 4 || lines != 12 {
+		t.Fail()
+	}
+	path := filepath.Join(t.TempDir(), "bulk.ndjson")
+	if err = writeBulkNDJSON(path, "zoomeye-hosts", docs); err != nil {
+		t.FailNow()
+	}
+	if b, _ := readFile(path); strings.Count(string(b), "\n") != 10 ||
+		!strings.HasPrefix(string(b), `{"index":{"_id":"10.0.0.1:80","_index":"zoomeye-hosts"}}`) {
+		t.Fail()
+	}
+}
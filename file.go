@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"encoding/csv"
 	"encoding/json"
 	"io/ioutil"
 	"os"
@@ -72,6 +73,29 @@ func readFile(path string) ([]byte, error) {
 	return ioutil.ReadFile(path)
 }
 
+// readLines reads non-empty lines of file (or stdin if path is "-"), lines start with # are ignored
+func readLines(path string) ([]string, error) {
+	var (
+		b   []byte
+		err error
+	)
+	if path == "-" {
+		b, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		b, err = readFile(abs(path))
+	}
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, v := range strings.Split(string(b), "\n") {
+		if v = strings.TrimSpace(v); v != "" && !strings.HasPrefix(v, "#") {
+			lines = append(lines, v)
+		}
+	}
+	return lines, nil
+}
+
 func readObject(dst interface{}, path string) error {
 	b, err := readFile(path)
 	if err != nil {
@@ -92,6 +116,29 @@ func writeObject(path string, src interface{}) error {
 	return writeFile(path, b)
 }
 
+func writeCSV(path string, columns []string, rows []map[string]interface{}) error {
+	var (
+		buf bytes.Buffer
+		w   = csv.NewWriter(&buf)
+	)
+	if err := w.Write(columns); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := make([]string, len(columns))
+		for i, k := range columns {
+			record[i] = toStr(row[k])
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	if w.Flush(); w.Error() != nil {
+		return w.Error()
+	}
+	return writeFile(path, buf.Bytes())
+}
+
 func appendToFile(path string, data []byte) error {
 	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
 	if err != nil {
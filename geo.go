@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gyyyy/ZoomEye-go/zoomeye"
+)
+
+// geoPlace represents a point of map, it is a match or a cluster of matches in the same city
+type geoPlace struct {
+	Name  string
+	Lat   float64
+	Lon   float64
+	Props map[string]interface{}
+}
+
+func toFloat(o interface{}) (float64, bool) {
+	switch v := o.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// geoPlaces places each match at its geoinfo.location, the matches without coordinates are skipped
+func geoPlaces(result *zoomeye.SearchResult) []*geoPlace {
+	places := make([]*geoPlace, 0, len(result.Matches))
+	for _, m := range result.Matches {
+		lat, ok1 := toFloat(m.Find("geoinfo.location.lat"))
+		lon, ok2 := toFloat(m.Find("geoinfo.location.lon"))
+		if !ok1 || !ok2 || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+			continue
+		}
+		p := &geoPlace{
+			Lat: lat,
+			Lon: lon,
+			Props: map[string]interface{}{
+				"ip":        m.FindString("ip"),
+				"country":   m.FindString("geoinfo.country.names.en"),
+				"city":      m.FindString("geoinfo.city.names.en"),
+				"timestamp": m.FindString("timestamp"),
+			},
+		}
+		if result.Type == "web" {
+			p.Name = m.FindString("site")
+			p.Props["site"] = p.Name
+			p.Props["title"] = m.FindString("title")
+			p.Props["app"] = withVersion(m.Find("webapp"))
+		} else {
+			p.Name = matchKey(result.Type, m)
+			p.Props["port"] = m.Find("portinfo.port")
+			p.Props["service"] = m.FindString("portinfo.service")
+			p.Props["app"] = strings.TrimSpace(m.FindString("portinfo.app") + " " + m.FindString("portinfo.version"))
+			p.Props["title"] = m.FindString("portinfo.title")
+		}
+		if p.Name == "" {
+			p.Name = m.FindString("ip")
+		}
+		for k, v := range p.Props {
+			if v == nil || v == "" {
+				delete(p.Props, k)
+			}
+		}
+		places = append(places, p)
+	}
+	return places
+}
+
+// clusterPlaces aggregates the places by country and city, each cluster is at the mean coordinates
+// of its places and has the count of matches, the IPs and the apps
+func clusterPlaces(places []*geoPlace) []*geoPlace {
+	var (
+		clusters []*geoPlace
+		indexes  = make(map[string]int)
+		sums     [][2]float64
+		ips      = make(map[int]map[string]bool)
+		apps     = make(map[int]map[string]int)
+	)
+	for _, p := range places {
+		var (
+			city, _    = p.Props["city"].(string)
+			country, _ = p.Props["country"].(string)
+			key        = country + "\x00" + city
+		)
+		i, ok := indexes[key]
+		if !ok {
+			name := city
+			if name == "" {
+				name = "[unknown]"
+			}
+			if country != "" {
+				name += ", " + country
+			}
+			i = len(clusters)
+			indexes[key] = i
+			ips[i], apps[i] = make(map[string]bool), make(map[string]int)
+			sums = append(sums, [2]float64{})
+			clusters = append(clusters, &geoPlace{
+				Name:  name,
+				Props: map[string]interface{}{"city": city, "country": country, "count": 0},
+			})
+		}
+		sums[i][0] += p.Lat
+		sums[i][1] += p.Lon
+		clusters[i].Props["count"] = clusters[i].Props["count"].(int) + 1
+		if ip, _ := p.Props["ip"].(string); ip != "" {
+			ips[i][ip] = true
+		}
+		if app, _ := p.Props["app"].(string); app != "" {
+			apps[i][app]++
+		}
+	}
+	for i, c := range clusters {
+		n := float64(c.Props["count"].(int))
+		c.Lat, c.Lon = math.Round(sums[i][0]/n*1e6)/1e6, math.Round(sums[i][1]/n*1e6)/1e6
+		list := make([]string, 0, len(ips[i]))
+		for ip := range ips[i] {
+			list = append(list, ip)
+		}
+		sort.Strings(list)
+		c.Props["ips"] = list
+		if len(apps[i]) > 0 {
+			c.Props["apps"] = apps[i]
+		}
+	}
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return clusters[i].Props["count"].(int) > clusters[j].Props["count"].(int)
+	})
+	return clusters
+}
+
+// geoJSON converts the places into a GeoJSON FeatureCollection of points
+func geoJSON(places []*geoPlace, meta *reportMeta) map[string]interface{} {
+	features := make([]interface{}, 0, len(places))
+	for _, p := range places {
+		props := make(map[string]interface{}, len(p.Props)+1)
+		for k, v := range p.Props {
+			props[k] = v
+		}
+		props["name"] = p.Name
+		features = append(features, map[string]interface{}{
+			"type": "Feature",
+			"geometry": map[string]interface{}{
+				"type":        "Point",
+				"coordinates": []float64{p.Lon, p.Lat},
+			},
+			"properties": props,
+		})
+	}
+	collection := map[string]interface{}{
+		"type":     "FeatureCollection",
+		"features": features,
+	}
+	if meta != nil {
+		collection["properties"] = map[string]interface{}{"dork": meta.Dork, "source": meta.Source}
+	}
+	return collection
+}
+
+func kmlText(buf *bytes.Buffer, tag, s string) {
+	fmt.Fprintf(buf, "<%s>", tag)
+	xml.EscapeText(buf, []byte(s))
+	fmt.Fprintf(buf, "</%s>", tag)
+}
+
+// kmlDocument converts the places into a KML document of placemarks with properties in ExtendedData
+func kmlDocument(places []*geoPlace, meta *reportMeta) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<kml xmlns="http://www.opengis.net/kml/2.2"><Document>`)
+	name := "ZoomEye"
+	if meta != nil && meta.Dork != "" {
+		name += ": " + meta.Dork
+	}
+	kmlText(&buf, "name", name)
+	buf.WriteString("\n")
+	for _, p := range places {
+		keys := make([]string, 0, len(p.Props))
+		for k := range p.Props {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buf.WriteString("<Placemark>")
+		kmlText(&buf, "name", p.Name)
+		if n, ok := p.Props["count"].(int); ok {
+			kmlText(&buf, "description", fmt.Sprintf("%d matches", n))
+		}
+		buf.WriteString("<ExtendedData>")
+		for _, k := range keys {
+			fmt.Fprintf(&buf, `<Data name="%s">`, xmlAttr(k))
+			v := p.Props[k]
+			if m, ok := v.(map[string]int); ok {
+				parts := make([]string, 0, len(m))
+				for app, n := range m {
+					parts = append(parts, fmt.Sprintf("%s (%d)", app, n))
+				}
+				sort.Strings(parts)
+				v = strings.Join(parts, ", ")
+			}
+			kmlText(&buf, "value", toStr(v))
+			buf.WriteString("</Data>")
+		}
+		buf.WriteString("</ExtendedData><Point>")
+		kmlText(&buf, "coordinates", strconv.FormatFloat(p.Lon, 'f', -1, 64)+","+strconv.FormatFloat(p.Lat, 'f', -1, 64))
+		buf.WriteString("</Point></Placemark>\n")
+	}
+	buf.WriteString("</Document></kml>\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/gyyyy/ZoomEye-go/zoomeye"
+)
+
+func TestGeoExport(t *testing.T) {
+	result := &zoomeye.SearchResult{Type: "host"}
+	if err := json.Unmarshal([]byte(`{"total": 4, "matches": [
+		{"ip": "10.0.0.1", "portinfo": {"port": 80, "app": "nginx", "version": "1.18.0"},
+			"geoinfo": {"location": {"lat": 39.9, "lon": 116.4}, "country": {"names": {"en": "China"}}, "city": {"names": {"en": "Beijing"}}}},
+		{"ip": "10.0.0.6", "portinfo": {"port": 80, "app": "nginx"},
+			"geoinfo": {"location": {"lat": 39.8, "lon": 116.5}, "country": {"names": {"en": "China"}}, "city": {"names": {"en": "Beijing"}}}},
+		{"ip": "10.0.0.11", "portinfo": {"port": 443, "app": "nginx"},
+			"geoinfo": {"location": {"lat": 35.7, "lon": 139.7}, "country": {"names": {"en": "Japan"}}, "city": {"names": {"en": "Tokyo"}}}},
+		{"ip": "10.0.0.16", "portinfo": {"port": 80, "app": "nginx"}}
+	]}`), result); err != nil {
+		t.FailNow()
+	}
+	places := geoPlaces(result)
+	if len(places) != 3 || places[0].Name != "10.0.0.1:80" || places[0].Props["app"] != "nginx 1.18.0" {
+		t.FailNow()
+	}
+	collection := geoJSON(places, &reportMeta{Dork: "nginx"})
+	if features := collection["features"].([]interface{}); len(features) != 3 {
+		t.Fail()
+	} else if coords := features[0].(map[string]interface{})["geometry"].(map[string]interface{})["coordinates"].([]float64); coords[0] != places[0].Lon || coords[1] != places[0].Lat {
+		t.Fail()
+	}
+	clusters := clusterPlaces(places)
+	var total int
+	for _, c := range clusters {
+		total += c.Props["count"].(int)
+		if len(c.Props["ips"].([]string)) != c.Props["count"].(int) {
+			t.Fail()
+		}
+	}
+	if total != 3 || len(clusters) != 2 || clusters[0].Name != "Beijing, China" {
+		t.Fail()
+	}
+	var doc struct {
+		Placemarks []struct {
+			Name        string `xml:"name"`
+			Description string `xml:"description"`
+			Coordinates string `xml:"Point>coordinates"`
+		} `xml:"Document>Placemark"`
+	}
+	if err := xml.Unmarshal(kmlDocument(clusters, nil), &doc); err != nil || len(doc.Placemarks) != len(clusters) {
+		t.FailNow()
+	}
+	if p := doc.Placemarks[0]; p.Name != clusters[0].Name || !strings.HasSuffix(p.Description, " matches") || strings.Count(p.Coordinates, ",") != 1 {
+		t.Fail()
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gyyyy/ZoomEye-go/zoomeye"
+)
+
+// incrementalState represents the newest data seen by incremental search of a dork
+type incrementalState struct {
+	Dork     string    `json:"dork"`
+	Resource string    `json:"resource"`
+	Newest   string    `json:"newest"`
+	Path     string    `json:"path"`
+	Updated  time.Time `json:"updated"`
+}
+
+// incrementalResult represents result of incremental search
+type incrementalResult struct {
+	New    *zoomeye.SearchResult
+	Merged *zoomeye.SearchResult
+	Since  string
+	State  *incrementalState
+}
+
+func (a *ZoomEyeAgent) incrementalStates() map[string]*incrementalState {
+	states := make(map[string]*incrementalState)
+	readObject(&states, filepath.Join(a.conf.ConfigPath, "incremental.json"))
+	return states
+}
+
+func newestTimestamp(result *zoomeye.SearchResult, newest string) string {
+	for _, m := range result.Matches {
+		if t := m.FindString("timestamp"); t > newest {
+			newest = t
+		}
+	}
+	return newest
+}
+
+// IncrementalSearch searches the data newer than the last run of the dork by after: constraint,
+// the new matches are merged into the stored result set which is kept in data path,
+// the newest timestamp is not moved forward if *zoomeye.PartialResultError is returned
+func (a *ZoomEyeAgent) IncrementalSearch(dork string, num int, resource string, opts ...zoomeye.SearchOption) (*incrementalResult, error) {
+	if resource = strings.ToLower(resource); resource != "web" {
+		resource = "host"
+	}
+	var (
+		key    = resource + ":" + dork
+		states = a.incrementalStates()
+		state  = states[key]
+		merged = &zoomeye.SearchResult{
+			Type: resource,
+		}
+		query = dork
+		since string
+	)
+	if state != nil {
+		if stored, err := a.Load(state.Path); err == nil {
+			merged = stored
+			merged.Type = resource
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("invalid stored result: %v", err)
+		} else {
+			state = nil
+		}
+	}
+	if state != nil && state.Newest != "" {
+		since = state.Newest
+		if len(since) > 10 {
+			since = since[:10]
+		}
+		query = strings.TrimSpace(fmt.Sprintf(`%s +after:"%s"`, dork, since))
+	}
+	result, err := a.Search(query, num, resource, true, opts...)
+	partial, _ := err.(*zoomeye.PartialResultError)
+	if err == zoomeye.ErrNoResults {
+		result, err = &zoomeye.SearchResult{
+			Type: resource,
+		}, nil
+	} else if result == nil || (err != nil && partial == nil) {
+		return nil, err
+	}
+	var (
+		newest  string
+		indexes = make(map[string]int)
+		fresh   = &zoomeye.SearchResult{
+			Type:  resource,
+			Total: result.Total,
+		}
+	)
+	if state != nil {
+		newest = state.Newest
+	}
+	for i, m := range merged.Matches {
+		indexes[matchKey(resource, m)] = i
+	}
+	for _, m := range result.Matches {
+		k := matchKey(resource, m)
+		i, ok := indexes[k]
+		if ok && m.FindString("timestamp") <= merged.Matches[i].FindString("timestamp") {
+			continue
+		}
+		if ok {
+			merged.Matches[i] = m
+		} else {
+			indexes[k] = len(merged.Matches)
+			merged.Matches = append(merged.Matches, m)
+		}
+		fresh.Matches = append(fresh.Matches, m)
+	}
+	merged.Total = uint64(len(merged.Matches))
+	if state == nil {
+		state = &incrementalState{
+			Dork:     dork,
+			Resource: resource,
+			Path:     filepath.Join(a.conf.DataPath, fmt.Sprintf("%s_incremental_%s.json", resource, url.QueryEscape(dork))),
+		}
+	}
+	if partial == nil {
+		// the matches of failed pages may be older than the fetched ones, keep newest until they are searched again
+		state.Newest = newestTimestamp(fresh, newest)
+	}
+	state.Updated = time.Now()
+	if err := writeObject(state.Path, merged); err != nil {
+		return nil, err
+	}
+	states[key] = state
+	if err := writeObject(filepath.Join(a.conf.ConfigPath, "incremental.json"), states); err != nil {
+		return nil, err
+	}
+	return &incrementalResult{
+		New:    fresh,
+		Merged: merged,
+		Since:  since,
+		State:  state,
+	}, err
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gyyyy/ZoomEye-go/zoomeye"
+)
+
+// ledgerEntry represents quota cost of an API call
+type ledgerEntry struct {
+	Time     time.Time `json:"time"`
+	Profile  string    `json:"profile"`
+	API      string    `json:"api"`
+	Resource string    `json:"resource,omitempty"`
+	Dork     string    `json:"dork,omitempty"`
+	Page     int       `json:"page,omitempty"`
+	Status   int       `json:"status"`
+	Cost     int       `json:"cost"`
+}
+
+type ledger struct {
+	path    string
+	profile string
+	mu      sync.Mutex
+}
+
+func (l *ledger) record(e *ledgerEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return appendToFile(l.path, b)
+}
+
+func (l *ledger) entries(since time.Time) ([]*ledgerEntry, error) {
+	f, err := os.Open(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	var (
+		entries []*ledgerEntry
+		scanner = bufio.NewScanner(f)
+	)
+	for scanner.Scan() {
+		e := &ledgerEntry{}
+		if json.Unmarshal(scanner.Bytes(), e) == nil && !e.Time.Before(since) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+func quotaCost(api string, body []byte) int {
+	switch api {
+	case "search":
+		var result struct {
+			Matches []json.RawMessage `json:"matches"`
+		}
+		if json.Unmarshal(body, &result) == nil {
+			return len(result.Matches)
+		}
+	case "history":
+		var result struct {
+			Count int `json:"count"`
+		}
+		if json.Unmarshal(body, &result) == nil {
+			return result.Count
+		}
+	}
+	return 0
+}
+
+// middleware records every API call and its quota cost
+func (l *ledger) middleware() zoomeye.Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return zoomeye.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				return nil, err
+			}
+			var (
+				query = req.URL.Query()
+				path  = strings.Trim(req.URL.Path, "/")
+				e     = &ledgerEntry{
+					Time:    time.Now(),
+					Profile: l.profile,
+					API:     path,
+					Status:  resp.StatusCode,
+				}
+			)
+			switch {
+			case query.Get("history") == "true":
+				e.API, e.Dork = "history", query.Get("ip")
+			case strings.HasSuffix(path, "/search"):
+				e.API, e.Resource, e.Dork = "search", strings.TrimSuffix(path, "/search"), query.Get("query")
+				e.Page, _ = strconv.Atoi(query.Get("page"))
+			}
+			if resp.StatusCode == http.StatusOK && (e.API == "search" || e.API == "history") {
+				b, err := ioutil.ReadAll(resp.Body)
+				if resp.Body.Close(); err != nil {
+					return nil, err
+				}
+				resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+				e.Cost = quotaCost(e.API, b)
+			}
+			l.record(e)
+			return resp, nil
+		})
+	}
+}
+
+func newLedger(conf *config) *ledger {
+	profile := conf.profileName()
+	if profile == "" {
+		profile = "default"
+	}
+	return &ledger{
+		path:    filepath.Join(conf.ConfigPath, "ledger.jsonl"),
+		profile: profile,
+	}
+}
+
+// usageItem represents summary of quota usage
+type usageItem struct {
+	Name  string
+	Calls int
+	Cost  int
+}
+
+func summarizeUsage(entries []*ledgerEntry, key func(*ledgerEntry) string) []*usageItem {
+	var (
+		m     = make(map[string]*usageItem)
+		items []*usageItem
+	)
+	for _, e := range entries {
+		k := key(e)
+		if k == "" {
+			continue
+		}
+		item, ok := m[k]
+		if !ok {
+			item = &usageItem{
+				Name: k,
+			}
+			m[k] = item
+			items = append(items, item)
+		}
+		item.Calls++
+		item.Cost += e.Cost
+	}
+	return items
+}
+
+// nextReset computes next reset time of quota by interval of resources
+func nextReset(interval string, now time.Time) (time.Time, bool) {
+	y, m, d := now.Date()
+	switch strings.ToLower(strings.TrimSpace(interval)) {
+	case "day", "daily":
+		return time.Date(y, m, d+1, 0, 0, 0, 0, now.Location()), true
+	case "week", "weekly":
+		days := (8 - int(now.Weekday())) % 7
+		if days == 0 {
+			days = 7
+		}
+		return time.Date(y, m, d+days, 0, 0, 0, 0, now.Location()), true
+	case "month", "monthly":
+		return time.Date(y, m+1, 1, 0, 0, 0, 0, now.Location()), true
+	case "year", "yearly":
+		return time.Date(y+1, 1, 1, 0, 0, 0, 0, now.Location()), true
+	}
+	return time.Time{}, false
+}
+
+// Usage reads quota usage of API calls since the specified time
+func (a *ZoomEyeAgent) Usage(since time.Time) ([]*ledgerEntry, error) {
+	entries, err := newLedger(a.conf).entries(since)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	return entries, nil
+}
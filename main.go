@@ -40,6 +40,9 @@ func help() {
 		"  search\n        Search results from local, cache or API\n"+
 		"  load\n        Load results from local data file\n"+
 		"  history\n        Query device history\n"+
+		"  report\n        Generate offline HTML report of results\n"+
+		"  export\n        Export results into Elasticsearch/OpenSearch\n"+
+		"  usage\n        Report quota usage recorded in local ledger\n"+
 		"  clear\n        Removes all cache and setting data\n"+
 		"  help\n        Usage of ZoomEye-go\n",
 		filepath.Base(os.Args[0]))
@@ -54,6 +57,7 @@ func main() {
 		cmd = os.Args[1]
 		os.Args = append(os.Args[0:1], os.Args[2:]...)
 	}
+	bindConfigFlags(agent.conf)
 	switch strings.ToLower(cmd) {
 	case "init":
 		cmdInit(agent)
@@ -65,6 +69,12 @@ func main() {
 		cmdLoad(agent)
 	case "history":
 		cmdHistory(agent)
+	case "report":
+		cmdReport(agent)
+	case "export":
+		cmdExport(agent)
+	case "usage":
+		cmdUsage(agent)
 	case "clear":
 		cmdClear(agent)
 	case "version", "-version", "--version", "ver", "-ver", "--ver", "-v", "--v":
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// outputFormat is the format of tables and information printed to stdout,
+// it is empty for colored terminal output or markdown for GitHub-flavoured markdown
+var outputFormat string
+
+var ansiRegexp = regexp.MustCompile("\033\\[[0-9;]*m")
+
+func isMarkdown() bool {
+	return strings.EqualFold(outputFormat, "markdown")
+}
+
+// mdEscape escapes the value to be placed in a cell of markdown table, line breaks are kept as <br>
+// and other control characters are replaced by their control pictures
+func mdEscape(o interface{}, maxWidth int) string {
+	var (
+		builder strings.Builder
+		runes   = []rune(strings.ReplaceAll(toStr(o), "\r\n", "\n"))
+	)
+	if maxWidth > 3 && len(runes) > maxWidth {
+		runes = append(runes[:maxWidth-3], '.', '.', '.')
+	}
+	for _, r := range runes {
+		switch {
+		case r == '\n':
+			builder.WriteString("<br>")
+		case r == '\t':
+			builder.WriteRune(' ')
+		case r == '<':
+			builder.WriteString("&lt;")
+		case r == '>':
+			builder.WriteString("&gt;")
+		case strings.ContainsRune("\\|`*_", r):
+			builder.WriteRune('\\')
+			builder.WriteRune(r)
+		case r < 32:
+			builder.WriteRune(0x2400 + r)
+		case r == 127:
+			builder.WriteRune(0x2421)
+		default:
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
+
+func mdRow(cells []string) string {
+	return "| " + strings.Join(cells, " | ") + " |\n"
+}
+
+func mdTablef(title string, head [][2]interface{}, body map[string][][]interface{}, count bool) {
+	var (
+		builder strings.Builder
+		names   []string
+		widths  []int
+		isGroup = len(head) > 0 && head[0][0].(string) != "-"
+		keys    = make([]string, 0, len(body))
+		total   int
+	)
+	for i, v := range head {
+		if i > 0 || isGroup {
+			names = append(names, mdEscape(v[0], 0))
+			widths = append(widths, v[1].(int))
+		}
+	}
+	builder.WriteString(mdRow(names))
+	builder.WriteString(strings.Repeat("| --- ", len(names)) + "|\n")
+	for k := range body {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range body[k] {
+			if len(v) < len(head)-1 {
+				continue
+			}
+			cells := make([]string, 0, len(names))
+			if isGroup {
+				cells = append(cells, mdEscape(k, widths[0]))
+			}
+			for _, o := range v[:len(head)-1] {
+				cells = append(cells, mdEscape(o, widths[len(cells)]))
+			}
+			builder.WriteString(mdRow(cells))
+			total++
+		}
+	}
+	if count {
+		builder.WriteString(fmt.Sprintf("\nTotal: %d\n", total))
+	}
+	fmt.Printf("### %s\n\n%s\n", title, builder.String())
+}
+
+func mdHtablef(title string, body []map[string]interface{}, widths [3]int, count bool) {
+	var (
+		builder strings.Builder
+		total   int
+	)
+	builder.WriteString(mdRow([]string{"Name", "Key", "Value"}))
+	builder.WriteString("| --- | --- | --- |\n")
+	for _, item := range body {
+		items, ok := item["items"].([]map[string]interface{})
+		if !ok {
+			// unexpected shape of facets or stats, the entry is skipped rather than breaking the whole report
+			continue
+		}
+		total++
+		name := mdEscape(item["name"], widths[0])
+		for i, v := range items {
+			if i > 0 {
+				name = ""
+			}
+			builder.WriteString(mdRow([]string{name, mdEscape(v["key"], widths[1]), mdEscape(v["value"], widths[2])}))
+		}
+	}
+	if count {
+		builder.WriteString(fmt.Sprintf("\nTotal: %d\n", total))
+	}
+	fmt.Printf("### %s\n\n%s\n", title, builder.String())
+}
+
+func mdInfof(title, format string, a ...interface{}) {
+	s := ansiRegexp.ReplaceAllString(fmt.Sprintf(format, a...), "")
+	if title != "" {
+		fmt.Printf("### %s\n\n", title)
+	}
+	fmt.Printf("```text\n%s\n```\n\n", strings.TrimRight(s, "\n"))
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gyyyy/ZoomEye-go/zoomeye"
+)
+
+func mispTag(k, v string) map[string]string {
+	return map[string]string{"name": fmt.Sprintf("zoomeye:%s=%q", k, v)}
+}
+
+// mispEvent converts the matches into a MISP event, the dork is kept in info and tags of the event,
+// and service/app of each match is kept in comment and tags of its attributes
+func mispEvent(result *zoomeye.SearchResult, meta *reportMeta, now time.Time) map[string]interface{} {
+	if meta == nil {
+		meta = &reportMeta{}
+	}
+	var (
+		ts         = strconv.FormatInt(now.Unix(), 10)
+		attributes []interface{}
+		seen       = make(map[string]bool)
+	)
+	add := func(typ, value, comment string, t time.Time, tags ...map[string]string) {
+		key := typ + ":" + value
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		attr := map[string]interface{}{
+			"uuid":       uuid5(zoomeyeNamespace, "misp:"+key),
+			"type":       typ,
+			"category":   "Network activity",
+			"value":      value,
+			"to_ids":     false,
+			"comment":    comment,
+			"timestamp":  ts,
+			"first_seen": t.UTC().Format(time.RFC3339),
+		}
+		if len(tags) > 0 {
+			attr["Tag"] = tags
+		}
+		attributes = append(attributes, attr)
+	}
+	for _, o := range observables(result, now) {
+		var tags []map[string]string
+		if o.Service != "" {
+			tags = append(tags, mispTag("service", o.Service))
+		}
+		if o.App != "" {
+			tags = append(tags, mispTag("app", o.App))
+		}
+		for _, ip := range o.IPs {
+			if o.Port > 0 {
+				add("ip-dst|port", ip+"|"+strconv.Itoa(o.Port), o.label(), o.Time, tags...)
+			} else {
+				add("ip-dst", ip, o.label(), o.Time, tags...)
+			}
+			for _, d := range o.Domains {
+				add("domain|ip", d+"|"+ip, o.label(), o.Time, tags...)
+			}
+		}
+		if o.ASN > 0 {
+			add("AS", "AS"+strconv.Itoa(o.ASN), o.Org, o.Time)
+		}
+		if c := o.Cert; c != nil {
+			if c.SHA1 != "" {
+				add("x509-fingerprint-sha1", c.SHA1, c.Subject, o.Time)
+			}
+			if c.SHA256 != "" {
+				add("x509-fingerprint-sha256", c.SHA256, c.Subject, o.Time)
+			}
+		}
+	}
+	return map[string]interface{}{
+		"Event": map[string]interface{}{
+			"uuid":            uuid5(zoomeyeNamespace, "misp:event:"+result.Type+":"+meta.Dork+":"+ts),
+			"info":            strings.TrimSpace(fmt.Sprintf("ZoomEye %s search: %s", result.Type, meta.Dork)),
+			"date":            now.Format("2006-01-02"),
+			"timestamp":       ts,
+			"threat_level_id": "4",
+			"analysis":        "0",
+			"distribution":    "0",
+			"published":       false,
+			"Tag": []map[string]string{
+				mispTag("dork", meta.Dork),
+				mispTag("resource", result.Type),
+			},
+			"Attribute": attributes,
+		},
+	}
+}
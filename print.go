@@ -3,8 +3,10 @@ package main
 import (
 	"fmt"
 	"math"
+	"os"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/gyyyy/ZoomEye-go/zoomeye"
 )
@@ -64,6 +66,10 @@ func colorf(s, color string) string {
 }
 
 func print(s, color string) {
+	if isMarkdown() {
+		fmt.Fprintln(os.Stderr, s)
+		return
+	}
 	fmt.Println(colorf(s, color))
 }
 
@@ -79,7 +85,27 @@ func warnf(format string, a ...interface{}) {
 	print(fmt.Sprintf(format, a...), colorYellow)
 }
 
+func progressf(title string) func(done, total int) {
+	return func(done, total int) {
+		if total <= 1 {
+			return
+		}
+		w := os.Stdout
+		if isMarkdown() {
+			w = os.Stderr
+		}
+		fmt.Fprint(w, colorf(fmt.Sprintf("\r%s: %d/%d", title, done, total), colorDarkWhite))
+		if done == total {
+			fmt.Fprintln(w)
+		}
+	}
+}
+
 func infof(title, format string, a ...interface{}) {
+	if isMarkdown() {
+		mdInfof(title, format, a...)
+		return
+	}
 	if title != "" {
 		format = "\n" + colorf("["+title+"]", colorLightCyan) + "\n\n" +
 			colorf("  "+strings.ReplaceAll(format, "\n", "\n  "), colorLightWhite) + "\n"
@@ -109,9 +135,9 @@ func toStr(o interface{}) string {
 	}
 }
 
-func omitStr(o interface{}, maxWidth int) string {
+func escapeCtrl(s string) string {
 	var builder strings.Builder
-	for _, r := range toStr(o) {
+	for _, r := range s {
 		if r > 31 && r < 127 {
 			builder.WriteRune(r)
 		} else if v, ok := ctrlChars[r]; ok {
@@ -120,7 +146,11 @@ func omitStr(o interface{}, maxWidth int) string {
 			builder.WriteString(fmt.Sprintf("\\x%02x", r))
 		}
 	}
-	s := builder.String()
+	return builder.String()
+}
+
+func omitStr(o interface{}, maxWidth int) string {
+	s := escapeCtrl(toStr(o))
 	if n := len(s); n > maxWidth {
 		if maxWidth > 3 {
 			s = s[:maxWidth-3] + "..."
@@ -132,6 +162,10 @@ func omitStr(o interface{}, maxWidth int) string {
 }
 
 func tablef(title string, head [][2]interface{}, body map[string][][]interface{}, count bool) {
+	if isMarkdown() {
+		mdTablef(title, head, body, count)
+		return
+	}
 	var (
 		builder strings.Builder
 		n       = len(head)
@@ -213,6 +247,10 @@ func tablef(title string, head [][2]interface{}, body map[string][][]interface{}
 }
 
 func htablef(title string, body []map[string]interface{}, widths [3]int, count bool) {
+	if isMarkdown() {
+		mdHtablef(title, body, widths, count)
+		return
+	}
 	var (
 		builder strings.Builder
 		hfmt    = fmt.Sprintf(colorf("|", colorLightBlack)+
@@ -352,10 +390,11 @@ func histf(title string, body map[string][][]interface{}) {
 			}
 			format := fmt.Sprintf("%%%ds  [%%%dd]  %%s", maxNameLen, maxCountLen)
 			for i, o := range v {
-				var (
-					n   = int(math.Round(float64(o[1].(uint64)) / float64(maxCount) * 36 * 8))
-					bar = strings.Repeat(histChars[7], n/8)
-				)
+				var n int
+				if maxCount > 0 {
+					n = int(math.Round(float64(o[1].(uint64)) / float64(maxCount) * 36 * 8))
+				}
+				bar := strings.Repeat(histChars[7], n/8)
 				if n%8 > 0 {
 					bar += histChars[n%8]
 				}
@@ -369,6 +408,60 @@ func histf(title string, body map[string][][]interface{}) {
 	infof(title, builder.String())
 }
 
+func stackf(title string, body map[string][][]interface{}) {
+	var builder strings.Builder
+	if len(body) > 0 {
+		first := true
+		for k, v := range body {
+			if !first {
+				builder.WriteString("\n\n\n")
+			} else {
+				first = false
+			}
+			builder.WriteString(colorf("Type: "+k, colorLightGreen) + "\n\n")
+			if len(v) > 10 {
+				others := []interface{}{"Others", uint64(0), float64(0)}
+				for _, o := range v[9:] {
+					others[1] = others[1].(uint64) + o[1].(uint64)
+					others[2] = others[2].(float64) + o[2].(float64)
+				}
+				v = append(append([][]interface{}{}, v[:9]...), others)
+			}
+			var total uint64
+			for _, o := range v {
+				total += o[1].(uint64)
+			}
+			var (
+				bar   strings.Builder
+				width int
+			)
+			for i, o := range v {
+				if total == 0 {
+					// all counts are zero, there is nothing to stack
+					break
+				}
+				n := int(math.Round(float64(o[1].(uint64)) / float64(total) * 60))
+				if i == len(v)-1 {
+					n = 60 - width
+				}
+				if n > 0 {
+					bar.WriteString(colorf(strings.Repeat(histChars[8], n), pieColors[i]))
+					width += n
+				}
+			}
+			builder.WriteString(bar.String() + "\n\n")
+			for i, o := range v {
+				builder.WriteString(colorf(fmt.Sprintf("%s %5.2f%%%% - %s  [%d]", histChars[8], o[2].(float64)*100,
+					omitStr(o[0], 35), o[1]), pieColors[i]))
+				if i < len(v)-1 {
+					builder.WriteString("\n")
+				}
+			}
+		}
+	}
+	infof(title, builder.String())
+}
+
 func withUnknown(o interface{}) string {
 	if s := toStr(o); s != "" && !strings.EqualFold(strings.TrimSpace(s), "unknown") {
 		return s
@@ -398,15 +491,8 @@ func withVersion(o interface{}) string {
 	return ""
 }
 
-func showFacet(result *zoomeye.SearchResult, facets []string, figure string) {
-	var (
-		head = [][2]interface{}{
-			{"Type", 10},
-			{"Name", 35},
-			{"Count", 20},
-		}
-		body = make(map[string][][]interface{})
-	)
+func facetBody(result *zoomeye.SearchResult, facets []string) map[string][][]interface{} {
+	body := make(map[string][][]interface{})
 	for _, f := range facets {
 		f = strings.ToLower(strings.TrimSpace(f))
 		s := f
@@ -416,15 +502,34 @@ func showFacet(result *zoomeye.SearchResult, facets []string, figure string) {
 		if facet, ok := result.Facets[s]; ok {
 			group := make([][]interface{}, 0, len(facet))
 			for _, v := range facet {
+				var ratio float64
+				if result.Total > 0 {
+					ratio = float64(v.Count) / float64(result.Total)
+				}
 				group = append(group, []interface{}{
 					withUnknown(v.Name),
 					v.Count,
-					float64(v.Count) / float64(result.Total),
+					ratio,
 				})
 			}
 			body[f] = group
 		}
 	}
+	return body
+}
+
+func showFacet(result *zoomeye.SearchResult, facets []string, figure string) {
+	var (
+		head = [][2]interface{}{
+			{"Type", 10},
+			{"Name", 35},
+			{"Count", 20},
+		}
+		body = facetBody(result, facets)
+	)
+	if isMarkdown() {
+		figure = ""
+	}
 	switch figure {
 	case "":
 		tablef("ZoomEye Facets", head, body, false)
@@ -432,18 +537,15 @@ func showFacet(result *zoomeye.SearchResult, facets []string, figure string) {
 		pief("ZoomEye Facets - PIE", body)
 	case "hist":
 		histf("ZoomEye Facets - HIST", body)
+	case "stack":
+		stackf("ZoomEye Facets - STACK", body)
+	case "map":
+		mapf("ZoomEye Facets - MAP", body)
 	}
 }
 
-func showStat(result *zoomeye.SearchResult, keys []string, figure string) {
-	var (
-		head = [][2]interface{}{
-			{"Type", 10},
-			{"Name", 35},
-			{"Count", 20},
-		}
-		body = make(map[string][][]interface{})
-	)
+func statBody(result *zoomeye.SearchResult, keys []string) map[string][][]interface{} {
+	body := make(map[string][][]interface{})
 	for s, stat := range result.Statistics(keys...) {
 		group := make([][]interface{}, 0, len(stat))
 		for k, v := range stat {
@@ -458,6 +560,21 @@ func showStat(result *zoomeye.SearchResult, keys []string, figure string) {
 		})
 		body[s] = group
 	}
+	return body
+}
+
+func showStat(result *zoomeye.SearchResult, keys []string, figure string) {
+	var (
+		head = [][2]interface{}{
+			{"Type", 10},
+			{"Name", 35},
+			{"Count", 20},
+		}
+		body = statBody(result, keys)
+	)
+	if isMarkdown() {
+		figure = ""
+	}
 	switch figure {
 	case "":
 		tablef("Result Statistics", head, body, false)
@@ -465,6 +582,10 @@ func showStat(result *zoomeye.SearchResult, keys []string, figure string) {
 		pief("Result Statistics - PIE", body)
 	case "hist":
 		histf("Result Statistics - HIST", body)
+	case "stack":
+		stackf("Result Statistics - STACK", body)
+	case "map":
+		mapf("Result Statistics - MAP", body)
 	}
 }
 
@@ -645,3 +766,334 @@ func showHistory(result *zoomeye.HistoryResult, keys []string, num int) {
 	infof("History Info", info)
 	tablef("History Result", head, map[string][][]interface{}{"": body}, true)
 }
+
+func showUsage(entries []*ledgerEntry, info *zoomeye.ResourcesInfoResult, days int) {
+	var spent, calls int
+	for _, e := range entries {
+		spent += e.Cost
+		calls++
+	}
+	summary := fmt.Sprintf("API Calls:         %d\nQuota Spent:       %d", calls, spent)
+	if info != nil && info.Resources != nil {
+		summary = fmt.Sprintf("Role:              %s\nRemaining Quota:   %d\nStats Quota:       %d\n"+
+			"Interval:          %s\n", info.Plan, info.Resources.Search, info.Resources.Stats, withUnknown(info.Resources.Interval)) + summary
+		if t, ok := nextReset(info.Resources.Interval, time.Now()); ok {
+			summary += fmt.Sprintf("\nNext Reset:        %s (in %v)", t.Format("2006-01-02 15:04:05"), time.Until(t).Round(time.Minute))
+		}
+	}
+	infof(fmt.Sprintf("Quota Usage (last %d days)", days), summary)
+	tables := []struct {
+		title, name string
+		key         func(*ledgerEntry) string
+		byName      bool
+	}{
+		{"Usage by Day", "Day", func(e *ledgerEntry) string {
+			return e.Time.Local().Format("2006-01-02")
+		}, true},
+		{"Usage by Dork", "Dork", func(e *ledgerEntry) string {
+			switch e.API {
+			case "search":
+				return "[" + e.Resource + "] " + e.Dork
+			case "history":
+				return "[history] " + e.Dork
+			}
+			return ""
+		}, false},
+		{"Usage by Profile", "Profile", func(e *ledgerEntry) string {
+			return e.Profile
+		}, false},
+	}
+	for _, t := range tables {
+		var (
+			items = summarizeUsage(entries, t.key)
+			head  = [][2]interface{}{
+				{"-", 0},
+				{t.name, 50},
+				{"Calls", 10},
+				{"Quota Cost", 12},
+			}
+			body = make([][]interface{}, len(items))
+		)
+		sort.SliceStable(items, func(i, j int) bool {
+			if t.byName {
+				return items[i].Name > items[j].Name
+			}
+			return items[i].Cost > items[j].Cost
+		})
+		for i, v := range items {
+			body[i] = []interface{}{v.Name, v.Calls, v.Cost}
+		}
+		tablef(t.title, head, map[string][][]interface{}{"": body}, false)
+	}
+}
+
+func showBatch(items []*batchItem) {
+	var (
+		head = [][2]interface{}{
+			{"-", 0},
+			{"Dork", 40},
+			{"Total", 12},
+			{"Fetched", 8},
+			{"Status", 8},
+			{"Error", 30},
+		}
+		body = make([][]interface{}, len(items))
+	)
+	for i, v := range items {
+		body[i] = []interface{}{v.Dork, v.Total, v.Fetched, v.Status, v.Error}
+	}
+	tablef("Batch Search Summary", head, map[string][][]interface{}{"": body}, false)
+}
+
+func showHistorySummary(items []*historyItem) {
+	var (
+		head = [][2]interface{}{
+			{"-", 0},
+			{"IP", 39},
+			{"Probes", 8},
+			{"Ports", 6},
+			{"Cached", 6},
+			{"Status", 8},
+			{"Error", 30},
+		}
+		body = make([][]interface{}, len(items))
+	)
+	for i, v := range items {
+		body[i] = []interface{}{v.IP, v.Probes, v.Ports, v.Cached, v.Status, v.Error}
+	}
+	tablef("Batch History Summary", head, map[string][][]interface{}{"": body}, false)
+}
+
+func showHistoryReport(result *zoomeye.HistoryResult, keys []string, num int) {
+	var names []string
+	for _, v := range keys {
+		if v = strings.ToLower(strings.TrimSpace(strings.SplitN(v, "=", 2)[0])); v != "" && v != "ip" {
+			names = append(names, v)
+		}
+	}
+	if len(names) == 0 {
+		names = []string{"time", "port", "service", "app"}
+		keys = append(keys, names...)
+	}
+	var (
+		filtered = result.Filter(append(keys, "ip")...)
+		head     = [][2]interface{}{
+			{"IP", 16},
+		}
+		body = make(map[string][][]interface{})
+	)
+	if len(filtered) == 0 {
+		infof("History Report", "no any historical data")
+		return
+	}
+	for _, v := range names {
+		width := 15
+		switch v {
+		case "time":
+			width = 19
+		case "port":
+			width = 5
+		case "raw":
+			width = 45
+		}
+		head = append(head, [2]interface{}{strings.Title(v), width})
+	}
+	for _, f := range filtered {
+		ip := toStr(f["ip"])
+		if num > 0 && len(body[ip]) >= num {
+			continue
+		}
+		row := make([]interface{}, 0, len(names))
+		for _, v := range names {
+			row = append(row, toStr(f[v]))
+		}
+		body[ip] = append(body[ip], row)
+	}
+	tablef("History Report", head, body, true)
+}
+
+func showShards(report *shardReport) {
+	var (
+		head = [][2]interface{}{
+			{"-", 0},
+			{"Dork", 50},
+			{"Total", 10},
+			{"Fetched", 8},
+			{"Unique", 8},
+			{"Status", 8},
+		}
+		body   = make([][]interface{}, len(report.Shards))
+		capped int
+	)
+	for i, v := range report.Shards {
+		status := v.Status
+		if v.Capped {
+			status += "*"
+			capped++
+		}
+		body[i] = []interface{}{v.Dork, v.Total, v.Fetched, v.Unique, status}
+	}
+	tablef("Shard Result", head, map[string][][]interface{}{"": body}, false)
+	info := fmt.Sprintf("Original Total:    %d\n"+
+		"Sharded Total:     %d\n"+
+		"Shards:            %d\n"+
+		"Unique Matches:    %d\n"+
+		"Duplicates:        %d\n"+
+		"Coverage:          %.2f%%", report.Total, report.Sharded, len(report.Shards), report.Unique, report.Duplicates, report.Coverage()*100)
+	if capped > 0 {
+		info += fmt.Sprintf("\n\n%d shards (marked *) still exceed the limit of sub-query, add more facets to -shard-by", capped)
+	}
+	infof("Shard Coverage", info)
+}
+
+func showIncremental(result *incrementalResult) {
+	since := result.Since
+	if since == "" {
+		since = "[first run]"
+	}
+	infof("Incremental Search", "Dork:              %s\n"+
+		"Searched After:    %s\n"+
+		"New Matches:       %d\n"+
+		"Stored Matches:    %d\n"+
+		"Newest Timestamp:  %s\n"+
+		"Stored Result:     %s", result.State.Dork, since, len(result.New.Matches), len(result.Merged.Matches),
+		withUnknown(result.State.Newest), result.State.Path)
+}
+
+func ganttf(title string, timelines []*zoomeye.ServiceTimeline, width int) {
+	if len(timelines) == 0 {
+		return
+	}
+	start, end := timelines[0].FirstSeen, timelines[0].LastSeen
+	for _, tl := range timelines {
+		if tl.FirstSeen.Before(start) {
+			start = tl.FirstSeen
+		}
+		if tl.LastSeen.After(end) {
+			end = tl.LastSeen
+		}
+	}
+	var (
+		builder strings.Builder
+		total   = end.Sub(start)
+		cell    = func(t time.Time) int {
+			if total <= 0 {
+				return 0
+			}
+			return int(float64(t.Sub(start)) / float64(total) * float64(width-1))
+		}
+	)
+	for _, tl := range timelines {
+		cells := make([]string, width)
+		for i := range cells {
+			cells[i] = colorf("·", colorDarkWhite)
+		}
+		for _, s := range tl.Spans {
+			for i := cell(s.Start); i <= cell(s.End); i++ {
+				cells[i] = colorf("█", colorLightGreen)
+			}
+		}
+		for _, e := range tl.Events {
+			switch e.Type {
+			case "change":
+				cells[cell(e.Time)] = colorf("◆", colorLightYellow)
+			case "close":
+				cells[cell(e.Time)] = colorf("✕", colorLightRed)
+			}
+		}
+		builder.WriteString(colorf(fmt.Sprintf("%15s  ", omitStr(tl.Name(), 15)), colorLightWhite) + strings.Join(cells, "") + "\n")
+	}
+	var (
+		from = start.Format("2006-01-02")
+		to   = end.Format("2006-01-02")
+		pad  = width - len(from) - len(to)
+	)
+	if pad < 1 {
+		pad = 1
+	}
+	builder.WriteString(colorf(fmt.Sprintf("%17s%s%s%s\n\n", "", from, strings.Repeat(" ", pad), to), colorDarkWhite))
+	builder.WriteString(colorf("█", colorLightGreen) + " seen  " + colorf("◆", colorLightYellow) + " product/version changed  " +
+		colorf("✕", colorLightRed) + " closed")
+	infof(title, builder.String())
+}
+
+func showTimeline(timelines []*zoomeye.ServiceTimeline) {
+	if len(timelines) == 0 {
+		infof("History Timeline", "no any historical data")
+		return
+	}
+	ganttf("History Timeline", timelines, 60)
+	var (
+		head = [][2]interface{}{
+			{"-", 0},
+			{"Port/Service", 15},
+			{"First Seen", 19},
+			{"Last Seen", 19},
+			{"Probes", 6},
+			{"Status", 6},
+		}
+		body   = make([][]interface{}, len(timelines))
+		events = make(map[string][][]interface{})
+	)
+	for i, tl := range timelines {
+		status := "open"
+		if !tl.Open {
+			status = "closed"
+		}
+		body[i] = []interface{}{tl.Name(), tl.FirstSeen.Format("2006-01-02T15:04:05"), tl.LastSeen.Format("2006-01-02T15:04:05"), tl.Probes, status}
+		for _, e := range tl.Events {
+			events[tl.Name()] = append(events[tl.Name()], []interface{}{e.Time.Format("2006-01-02T15:04:05"), e.Type, e.Detail})
+		}
+	}
+	tablef("Port/Service Summary", head, map[string][][]interface{}{"": body}, false)
+	tablef("Timeline Events", [][2]interface{}{
+		{"Port/Service", 15},
+		{"Time", 19},
+		{"Event", 6},
+		{"Detail", 45},
+	}, events, false)
+}
+
+func highlightVersions(s, color string) string {
+	return versionRegexp.ReplaceAllStringFunc(s, func(v string) string {
+		return colorReset + colorf(v, colorLightYellow) + color
+	})
+}
+
+func showBannerDiffs(diffs []*bannerDiff, probes int) {
+	if len(diffs) == 0 {
+		infof("Banner Diff", "no any banner changes in %d historical probes", probes)
+		return
+	}
+	for _, d := range diffs {
+		var builder strings.Builder
+		builder.WriteString(colorf(fmt.Sprintf("--- %s  %s", d.From, d.FromApp), colorLightRed) + "\n")
+		builder.WriteString(colorf(fmt.Sprintf("+++ %s  %s", d.To, d.ToApp), colorLightGreen) + "\n")
+		for _, h := range d.Hunks {
+			builder.WriteString(colorf(h.String(), colorLightCyan) + "\n")
+			for _, l := range h.Lines {
+				text := escapeCtrl(l.Text)
+				switch l.Op {
+				case '-':
+					builder.WriteString(colorf("-"+highlightVersions(text, colorRed), colorRed) + "\n")
+				case '+':
+					builder.WriteString(colorf("+"+highlightVersions(text, colorGreen), colorGreen) + "\n")
+				default:
+					builder.WriteString(colorf(" "+text, colorWhite) + "\n")
+				}
+			}
+		}
+		if len(d.Headers) > 0 {
+			builder.WriteString("\n" + colorf("Header Changes:", colorLightPurple) + "\n")
+			for _, v := range d.Headers {
+				builder.WriteString("  " + highlightVersions(escapeCtrl(v), "") + "\n")
+			}
+		}
+		title := "Banner Diff - " + d.Port
+		if d.Service != "" {
+			title += "/" + d.Service
+		}
+		infof(title, strings.ReplaceAll(strings.TrimSuffix(builder.String(), "\n"), "%", "%%"))
+	}
+	infof("", "%d banner changes in %d historical probes", len(diffs), probes)
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"html/template"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gyyyy/ZoomEye-go/zoomeye"
+)
+
+// defaultReportStats are the fields counted in report if no -stat is specified
+var defaultReportStats = map[string][]string{
+	"host": {"app", "service", "country"},
+	"web":  {"webapp", "server", "country"},
+}
+
+// reportMeta represents how the results of report are retrieved
+type reportMeta struct {
+	Dork   string
+	Source string
+}
+
+type reportRow struct {
+	Cells  []string
+	Detail string
+}
+
+type reportChart struct {
+	Title string
+	SVG   template.HTML
+}
+
+type reportData struct {
+	Dork      string
+	Source    string
+	Type      string
+	Version   string
+	Generated string
+	Total     uint64
+	Fetched   int
+	Charts    []*reportChart
+	Columns   []string
+	Rows      []*reportRow
+	Detail    string
+}
+
+// dorkOfFile guesses the dork from name of data file saved by search, such as host_weblogic_20.json
+func dorkOfFile(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	i, j := strings.Index(name, "_"), strings.LastIndex(name, "_")
+	if i < 0 || j <= i {
+		return ""
+	}
+	dork, err := url.QueryUnescape(name[i+1 : j])
+	if err != nil {
+		return ""
+	}
+	return dork
+}
+
+func reportRows(result *zoomeye.SearchResult) ([]string, []*reportRow, string) {
+	rows := make([]*reportRow, len(result.Matches))
+	if result.Type == "web" {
+		for i, v := range result.Matches {
+			rows[i] = &reportRow{
+				Cells: []string{
+					v.FindString("site"),
+					v.FindString("ip"),
+					v.FindString("title"),
+					withVersion(v.Find("webapp")),
+					withVersion(v.Find("server")),
+					v.FindString("geoinfo.country.names.en"),
+					v.FindString("geoinfo.city.names.en"),
+					v.FindString("timestamp"),
+				},
+				Detail: v.FindString("headers"),
+			}
+		}
+		return []string{"Site", "IP", "Title", "Application", "Server", "Country", "City", "Time"}, rows, "Headers"
+	}
+	for i, v := range result.Matches {
+		rows[i] = &reportRow{
+			Cells: []string{
+				v.FindString("ip") + ":" + v.FindString("portinfo.port"),
+				v.FindString("portinfo.app"),
+				v.FindString("portinfo.version"),
+				v.FindString("portinfo.service"),
+				v.FindString("portinfo.os"),
+				v.FindString("geoinfo.country.names.en"),
+				v.FindString("geoinfo.city.names.en"),
+				v.FindString("timestamp"),
+			},
+			Detail: v.FindString("portinfo.banner"),
+		}
+	}
+	return []string{"Host", "Application", "Version", "Service", "OS", "Country", "City", "Time"}, rows, "Banner"
+}
+
+func reportCharts(title, figure string, body map[string][][]interface{}) []*reportChart {
+	var charts []*reportChart
+	for _, s := range newChartSeries(title, body) {
+		if len(s.Items) == 0 {
+			continue
+		}
+		charts = append(charts, &reportChart{
+			Title: s.Title,
+			SVG:   template.HTML(chartSVG(figure, []*chartSeries{s})),
+		})
+	}
+	return charts
+}
+
+// writeReport generates a self-contained HTML report of the results, all facets of the results are
+// drawn if facets is empty, and the default fields of the resource are counted if stats is empty
+func writeReport(path string, result *zoomeye.SearchResult, meta *reportMeta, facets, stats []string, figure string) error {
+	if len(facets) == 0 {
+		for k := range result.Facets {
+			facets = append(facets, k)
+		}
+		sort.Strings(facets)
+	}
+	if len(stats) == 0 {
+		stats = defaultReportStats[result.Type]
+	}
+	if meta == nil {
+		meta = &reportMeta{}
+	}
+	data := &reportData{
+		Dork:      meta.Dork,
+		Source:    meta.Source,
+		Type:      result.Type,
+		Version:   ver,
+		Generated: time.Now().Format("2006-01-02 15:04:05"),
+		Total:     result.Total,
+		Fetched:   len(result.Matches),
+	}
+	data.Charts = append(reportCharts("ZoomEye Facets", figure, facetBody(result, facets)),
+		reportCharts("Result Statistics", figure, statBody(result, stats))...)
+	data.Columns, data.Rows, data.Detail = reportRows(result)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return reportTemplate.Execute(f, data)
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>ZoomEye Report{{if .Dork}} - {{.Dork}}{{end}}</title>
+<style>
+body { margin: 0; padding: 24px 32px; font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #333; background: #f5f6f8; }
+h1 { margin: 0 0 16px; font-size: 24px; }
+h2 { margin: 32px 0 12px; font-size: 18px; }
+.meta { display: grid; grid-template-columns: max-content auto; gap: 6px 24px; padding: 16px; background: #fff; border-radius: 6px; }
+.meta dt { color: #888; }
+.meta dd { margin: 0; font-family: monospace; word-break: break-all; }
+.charts { display: flex; flex-wrap: wrap; gap: 16px; }
+.chart { background: #fff; border-radius: 6px; overflow-x: auto; }
+.chart svg { display: block; max-width: 100%; height: auto; }
+#filter { width: 320px; padding: 6px 8px; margin-bottom: 8px; border: 1px solid #ccc; border-radius: 4px; }
+table { width: 100%; border-collapse: collapse; background: #fff; font-size: 13px; }
+th, td { padding: 6px 8px; border-bottom: 1px solid #e5e7eb; text-align: left; vertical-align: top; }
+th { cursor: pointer; user-select: none; background: #eef1f5; position: sticky; top: 0; }
+th.asc::after { content: " \25B2"; }
+th.desc::after { content: " \25BC"; }
+details summary { cursor: pointer; color: #3b82f6; }
+pre { margin: 6px 0 0; max-width: 640px; max-height: 320px; overflow: auto; white-space: pre-wrap; word-break: break-all; }
+</style>
+</head>
+<body>
+<h1>ZoomEye Report</h1>
+<dl class="meta">
+<dt>Dork</dt><dd>{{if .Dork}}{{.Dork}}{{else}}-{{end}}</dd>
+<dt>Source</dt><dd>{{.Source}}</dd>
+<dt>Type</dt><dd>{{.Type}}</dd>
+<dt>Total</dt><dd>{{.Total}}</dd>
+<dt>Fetched</dt><dd>{{.Fetched}}</dd>
+<dt>Generated</dt><dd>{{.Generated}} by ZoomEye-go {{.Version}}</dd>
+</dl>
+{{if .Charts}}<h2>Charts</h2>
+<div class="charts">
+{{range .Charts}}<div class="chart" title="{{.Title}}">{{.SVG}}</div>
+{{end}}</div>
+{{end}}<h2>Results</h2>
+<input id="filter" type="search" placeholder="Filter results">
+<span id="count">{{len .Rows}}</span> rows
+<table id="results">
+<thead><tr>{{range .Columns}}<th>{{.}}</th>{{end}}<th>{{.Detail}}</th></tr></thead>
+<tbody>
+{{range .Rows}}<tr>{{range .Cells}}<td>{{.}}</td>{{end}}<td>{{if .Detail}}<details><summary>show</summary><pre>{{.Detail}}</pre></details>{{end}}</td></tr>
+{{end}}</tbody>
+</table>
+<script>
+(function () {
+  var table = document.getElementById("results"),
+      body = table.tBodies[0],
+      heads = table.tHead.rows[0].cells;
+  function value(row, i) {
+    return row.cells[i].textContent.trim();
+  }
+  function compare(a, b) {
+    var x = parseFloat(a), y = parseFloat(b);
+    if (!isNaN(x) && !isNaN(y) && String(x) === a && String(y) === b) {
+      return x - y;
+    }
+    return a.localeCompare(b, undefined, {numeric: true});
+  }
+  Array.prototype.forEach.call(heads, function (th, i) {
+    if (i === heads.length - 1) {
+      return;
+    }
+    th.addEventListener("click", function () {
+      var desc = th.classList.contains("asc"),
+          rows = Array.prototype.slice.call(body.rows);
+      Array.prototype.forEach.call(heads, function (h) {
+        h.classList.remove("asc", "desc");
+      });
+      th.classList.add(desc ? "desc" : "asc");
+      rows.sort(function (a, b) {
+        var n = compare(value(a, i), value(b, i));
+        return desc ? -n : n;
+      });
+      rows.forEach(function (row) {
+        body.appendChild(row);
+      });
+    });
+  });
+  document.getElementById("filter").addEventListener("input", function () {
+    var words = this.value.toLowerCase().split(/\s+/).filter(Boolean),
+        count = 0;
+    Array.prototype.forEach.call(body.rows, function (row) {
+      var text = row.textContent.toLowerCase(),
+          show = words.every(function (w) {
+            return text.indexOf(w) >= 0;
+          });
+      row.style.display = show ? "" : "none";
+      if (show) {
+        count++;
+      }
+    });
+    document.getElementById("count").textContent = count;
+  });
+})();
+</script>
+</body>
+</html>
+`))
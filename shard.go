@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gyyyy/ZoomEye-go/zoomeye"
+)
+
+// defaultShardLimit is the default max results of a sub-query when sharding a dork
+const defaultShardLimit = 10000
+
+// defaultShardFields are the facets used to split a dork into sub-queries
+var defaultShardFields = []string{"country", "port", "service"}
+
+// shard represents a sub-query of the sharded dork
+type shard struct {
+	Dork    string `json:"dork"`
+	Total   uint64 `json:"total"`
+	Fetched int    `json:"fetched"`
+	Unique  int    `json:"unique"`
+	Capped  bool   `json:"capped"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+// shardReport represents coverage of the sharded search versus the original dork
+type shardReport struct {
+	Dork       string   `json:"dork"`
+	Total      uint64   `json:"total"`
+	Sharded    uint64   `json:"sharded"`
+	Unique     int      `json:"unique"`
+	Duplicates int      `json:"duplicates"`
+	Shards     []*shard `json:"shards"`
+}
+
+// Coverage returns ratio of unique matches to the original total
+func (r *shardReport) Coverage() float64 {
+	if r.Total == 0 {
+		return 0
+	}
+	return float64(r.Unique) / float64(r.Total)
+}
+
+type facetItem struct {
+	name  string
+	count uint64
+}
+
+func facetItems(result *zoomeye.SearchResult, field string) []*facetItem {
+	items, ok := result.Facets[field]
+	if !ok && field == "app" {
+		items = result.Facets["product"]
+	}
+	facets := make([]*facetItem, 0, len(items))
+	for _, v := range items {
+		if name := toStr(v.Name); name != "" && v.Count > 0 {
+			facets = append(facets, &facetItem{name, v.Count})
+		}
+	}
+	return facets
+}
+
+// planShards splits the dork by facets of fields in turn until total of each sub-query fits within limit,
+// values out of the top facets are covered by a sub-query that excludes all of them
+func (a *ZoomEyeAgent) planShards(dork, resource string, fields []string, limit uint64) (uint64, []*shard, error) {
+	var (
+		shards []*shard
+		split  func(dork string, total uint64, depth int) (uint64, error)
+	)
+	split = func(dork string, total uint64, depth int) (uint64, error) {
+		if (total > 0 && total <= limit) || depth >= len(fields) {
+			shards = append(shards, &shard{
+				Dork:   dork,
+				Total:  total,
+				Capped: total > limit,
+			})
+			return total, nil
+		}
+		field := fields[depth]
+		result, err := a.zoom.DorkSearch(dork, 1, resource, field)
+		if err == zoomeye.ErrNoResults && depth > 0 {
+			// a facet value or the rest of them may have no any results now, it is an empty shard
+			return split(dork, 0, len(fields))
+		} else if err != nil {
+			return 0, err
+		}
+		if total = result.Total; total <= limit {
+			return split(dork, total, len(fields))
+		}
+		items := facetItems(result, field)
+		if len(items) == 0 {
+			return split(dork, total, depth+1)
+		}
+		var (
+			covered uint64
+			rest    = dork
+		)
+		for _, v := range items {
+			if _, err = split(strings.TrimSpace(fmt.Sprintf(`%s +%s:"%s"`, dork, field, v.name)), v.count, depth+1); err != nil {
+				return 0, err
+			}
+			covered += v.count
+			rest += fmt.Sprintf(` -%s:"%s"`, field, v.name)
+		}
+		if covered < total {
+			if _, err = split(strings.TrimSpace(rest), total-covered, depth+1); err != nil {
+				return 0, err
+			}
+		}
+		return total, nil
+	}
+	total, err := split(dork, 0, 0)
+	return total, shards, err
+}
+
+func matchKey(resource string, m interface{ FindString(string) string }) string {
+	if resource == "web" {
+		if site := m.FindString("site"); site != "" {
+			return site
+		}
+		return m.FindString("ip")
+	}
+	return m.FindString("ip") + ":" + m.FindString("portinfo.port")
+}
+
+// ShardSearch retrieves results of a large dork beyond per-query cap by splitting it into sub-queries,
+// the matches of all sub-queries are deduplicated by ip:port (host) or site (web)
+func (a *ZoomEyeAgent) ShardSearch(dork string, num int, resource string, force bool, fields []string, limit int,
+	opts ...zoomeye.SearchOption) (*zoomeye.SearchResult, *shardReport, error) {
+	if a.zoom == nil {
+		if _, err := a.InitLocal(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if resource = strings.ToLower(resource); resource != "web" {
+		resource = "host"
+	}
+	if len(fields) == 0 {
+		fields = defaultShardFields
+	}
+	if limit <= 0 {
+		limit = defaultShardLimit
+	}
+	total, shards, err := a.planShards(dork, resource, fields, uint64(limit))
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		report = &shardReport{
+			Dork:   dork,
+			Total:  total,
+			Shards: shards,
+		}
+		combined = &zoomeye.SearchResult{
+			Type:  resource,
+			Total: total,
+		}
+		seen = make(map[string]struct{})
+	)
+	for _, s := range shards {
+		need := num - report.Unique
+		if need <= 0 {
+			s.Status = "skipped"
+			continue
+		} else if s.Total == 0 {
+			s.Status = "empty"
+			continue
+		}
+		if s.Total > 0 && uint64(need) > s.Total {
+			need = int(s.Total)
+		}
+		if need > limit {
+			need = limit
+		}
+		result, err := a.Search(s.Dork, need, resource, force, opts...)
+		switch err.(type) {
+		case nil:
+			s.Status = "ok"
+		case *zoomeye.PartialResultError:
+			s.Status, s.Error = "partial", err.Error()
+		default:
+			s.Status, s.Error = "failed", err.Error()
+			continue
+		}
+		s.Total, s.Fetched = result.Total, len(result.Matches)
+		for _, m := range result.Matches {
+			key := matchKey(resource, m)
+			if _, ok := seen[key]; ok {
+				report.Duplicates++
+				continue
+			}
+			seen[key] = struct{}{}
+			combined.Matches = append(combined.Matches, m)
+			s.Unique++
+			report.Unique++
+		}
+	}
+	var failed int
+	for _, s := range shards {
+		if report.Sharded += s.Total; s.Status == "failed" {
+			failed++
+		}
+	}
+	if failed == len(shards) {
+		return nil, report, fmt.Errorf("all of %d shards failed", len(shards))
+	}
+	return combined, report, nil
+}
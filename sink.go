@@ -0,0 +1,409 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gyyyy/ZoomEye-go/zoomeye"
+)
+
+const (
+	// defaultSinkBatch is the default number of events sent at once
+	defaultSinkBatch = 100
+	// syslogUDPMax is the max length of syslog message over UDP, the longer message is truncated
+	syslogUDPMax = 8192
+)
+
+// defaultSinkFields are the default event fields and paths of them in matches of each resource,
+// they are replaced by FIELDS of the sink in conf.yml
+var defaultSinkFields = map[string]map[string]string{
+	"host": {
+		"ip":        "ip",
+		"dork":      "_dork",
+		"port":      "portinfo.port",
+		"service":   "portinfo.service",
+		"app":       "portinfo.app",
+		"version":   "portinfo.version",
+		"os":        "portinfo.os",
+		"hostname":  "portinfo.hostname",
+		"country":   "geoinfo.country.names.en",
+		"city":      "geoinfo.city.names.en",
+		"asn":       "geoinfo.asn",
+		"org":       "geoinfo.organization",
+		"timestamp": "timestamp",
+	},
+	"web": {
+		"site":      "site",
+		"ip":        "ip",
+		"dork":      "_dork",
+		"title":     "title",
+		"webapp":    "webapp",
+		"server":    "server",
+		"country":   "geoinfo.country.names.en",
+		"city":      "geoinfo.city.names.en",
+		"timestamp": "timestamp",
+	},
+	"history": {
+		"ip":        "ip",
+		"port":      "portinfo.port",
+		"service":   "portinfo.service",
+		"app":       "portinfo.product",
+		"version":   "portinfo.version",
+		"timestamp": "timestamp",
+	},
+}
+
+// sinkConfig represents an output sink configured in SINKS of conf.yml
+type sinkConfig struct {
+	Name        string                       `yaml:"NAME"`
+	Type        string                       `yaml:"TYPE"`
+	Address     string                       `yaml:"ADDRESS,omitempty"`
+	AppName     string                       `yaml:"APP_NAME,omitempty"`
+	Facility    *int                         `yaml:"FACILITY,omitempty"`
+	Severity    *int                         `yaml:"SEVERITY,omitempty"`
+	URL         string                       `yaml:"URL,omitempty"`
+	Token       string                       `yaml:"TOKEN,omitempty"`
+	Index       string                       `yaml:"INDEX,omitempty"`
+	SourceType  string                       `yaml:"SOURCETYPE,omitempty"`
+	TLSInsecure bool                         `yaml:"TLS_INSECURE,omitempty"`
+	BatchSize   int                          `yaml:"BATCH_SIZE,omitempty"`
+	Fields      map[string]map[string]string `yaml:"FIELDS,omitempty"`
+}
+
+// sinkEvent represents a match forwarded to sinks
+type sinkEvent struct {
+	Time   time.Time
+	Fields map[string]interface{}
+}
+
+// finder is a match of search or history results
+type finder interface {
+	Find(expr string) interface{}
+}
+
+// sink sends events to SIEM
+type sink interface {
+	Send(events []*sinkEvent) error
+	Close() error
+}
+
+func eventValue(v interface{}) interface{} {
+	if list, ok := v.([]interface{}); ok {
+		for _, o := range list {
+			if _, ok := o.(map[string]interface{}); ok {
+				return withVersion(list)
+			}
+		}
+	}
+	return v
+}
+
+// sinkEvents maps the matches of kind (host, web or history) into events by FIELDS of the sink,
+// the context fields such as dork are added to each event unless the event has had them
+func sinkEvents(conf *sinkConfig, kind string, matches []finder, ctx map[string]interface{}) []*sinkEvent {
+	fields := conf.Fields[kind]
+	if len(fields) == 0 {
+		fields = defaultSinkFields[kind]
+	}
+	events := make([]*sinkEvent, 0, len(matches))
+	for _, m := range matches {
+		e := &sinkEvent{
+			Time:   time.Now(),
+			Fields: make(map[string]interface{}, len(fields)+len(ctx)),
+		}
+		for k, path := range fields {
+			if v := eventValue(m.Find(path)); v != nil {
+				e.Fields[k] = v
+			}
+		}
+		for k, v := range ctx {
+			if _, ok := e.Fields[k]; !ok && v != nil && v != "" {
+				e.Fields[k] = v
+			}
+		}
+		if t, ok := zoomeye.ParseTime(toStr(m.Find("timestamp"))); ok {
+			e.Time = t
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+// syslogSink sends events as RFC 5424 messages over UDP, TCP or TLS,
+// messages over TCP and TLS are framed by octet counting (RFC 6587)
+type syslogSink struct {
+	conf     *sinkConfig
+	network  string
+	conn     net.Conn
+	hostname string
+}
+
+func newSyslogSink(conf *sinkConfig) (*syslogSink, error) {
+	if conf.Facility != nil && (*conf.Facility < 0 || *conf.Facility > 23) {
+		return nil, fmt.Errorf("invalid syslog facility %d, it should be 0-23", *conf.Facility)
+	}
+	if conf.Severity != nil && (*conf.Severity < 0 || *conf.Severity > 7) {
+		return nil, fmt.Errorf("invalid syslog severity %d, it should be 0-7", *conf.Severity)
+	}
+	addr := conf.Address
+	if !strings.Contains(addr, "://") {
+		addr = "udp://" + addr
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		s = &syslogSink{
+			conf:    conf,
+			network: strings.ToLower(u.Scheme),
+		}
+		dialer = &net.Dialer{
+			Timeout: 10 * time.Second,
+		}
+	)
+	switch s.network {
+	case "udp", "tcp":
+		s.conn, err = dialer.Dial(s.network, u.Host)
+	case "tls":
+		s.conn, err = tls.DialWithDialer(dialer, "tcp", u.Host, &tls.Config{
+			ServerName:         u.Hostname(),
+			InsecureSkipVerify: conf.TLSInsecure,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported syslog protocol %s", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.hostname, _ = os.Hostname(); s.hostname == "" {
+		s.hostname = "-"
+	}
+	return s, nil
+}
+
+func sdName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= 32 || r >= 127 || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, s)
+	if len(s) > 32 {
+		s = s[:32]
+	}
+	return s
+}
+
+func sdValue(v interface{}) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(toStr(v))
+}
+
+func (s *syslogSink) message(e *sinkEvent) []byte {
+	var (
+		facility, severity = 1, 6
+		app                = s.conf.AppName
+		keys               = make([]string, 0, len(e.Fields))
+		sd                 strings.Builder
+	)
+	// 0 is valid for both (kern and emerg), so only the unset ones are defaulted
+	if s.conf.Facility != nil {
+		facility = *s.conf.Facility
+	}
+	if s.conf.Severity != nil {
+		severity = *s.conf.Severity
+	}
+	if app == "" {
+		app = "zoomeye"
+	}
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	sd.WriteString("[zoomeye@32473")
+	for _, k := range keys {
+		fmt.Fprintf(&sd, ` %s="%s"`, sdName(k), sdValue(e.Fields[k]))
+	}
+	sd.WriteString("]")
+	msg, _ := json.Marshal(e.Fields)
+	b := []byte(fmt.Sprintf("<%d>1 %s %s %s %d match %s %s", facility*8+severity,
+		e.Time.UTC().Format("2006-01-02T15:04:05.000000Z07:00"), s.hostname, sdName(app), os.Getpid(), sd.String(), msg))
+	if s.network == "udp" && len(b) > syslogUDPMax {
+		b = b[:syslogUDPMax]
+	}
+	return b
+}
+
+func (s *syslogSink) Send(events []*sinkEvent) error {
+	if s.network == "udp" {
+		for _, e := range events {
+			if _, err := s.conn.Write(s.message(e)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var buf bytes.Buffer
+	for _, e := range events {
+		msg := s.message(e)
+		fmt.Fprintf(&buf, "%d ", len(msg))
+		buf.Write(msg)
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+	_, err := s.conn.Write(buf.Bytes())
+	return err
+}
+
+func (s *syslogSink) Close() error {
+	return s.conn.Close()
+}
+
+// splunkSink sends events to Splunk HTTP Event Collector, events of a batch are sent in one request
+type splunkSink struct {
+	conf *sinkConfig
+	url  string
+	cli  *http.Client
+}
+
+func newSplunkSink(conf *sinkConfig) (*splunkSink, error) {
+	u, err := url.Parse(conf.URL)
+	if err != nil {
+		return nil, err
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid Splunk HEC URL %s", conf.URL)
+	} else if conf.Token == "" {
+		return nil, fmt.Errorf("token of Splunk HEC missing")
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/services/collector/event"
+	}
+	return &splunkSink{
+		conf: conf,
+		url:  u.String(),
+		cli: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: conf.TLSInsecure},
+			},
+		},
+	}, nil
+}
+
+func (s *splunkSink) Send(events []*sinkEvent) error {
+	var buf bytes.Buffer
+	for _, e := range events {
+		v := map[string]interface{}{
+			"time":       float64(e.Time.UnixNano()/int64(time.Millisecond)) / 1000,
+			"source":     "zoomeye",
+			"sourcetype": s.conf.SourceType,
+			"event":      e.Fields,
+		}
+		if v["sourcetype"] == "" {
+			v["sourcetype"] = "zoomeye"
+		}
+		if s.conf.Index != "" {
+			v["index"] = s.conf.Index
+		}
+		if ip := toStr(e.Fields["ip"]); ip != "" {
+			v["host"] = ip
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Splunk "+s.conf.Token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.cli.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("rejected by Splunk HEC (%d): %s", resp.StatusCode, shortStr(escapeCtrl(string(b)), 200))
+	}
+	return nil
+}
+
+func (s *splunkSink) Close() error {
+	return nil
+}
+
+func openSink(conf *sinkConfig) (sink, error) {
+	switch strings.ToLower(conf.Type) {
+	case "syslog":
+		return newSyslogSink(conf)
+	case "splunk", "hec":
+		return newSplunkSink(conf)
+	default:
+		return nil, fmt.Errorf("unsupported sink type %s", conf.Type)
+	}
+}
+
+// selectSinks returns the configured sinks by names, all sinks are returned if names is "all"
+func selectSinks(sinks []*sinkConfig, names string) ([]*sinkConfig, error) {
+	if strings.EqualFold(strings.TrimSpace(names), "all") {
+		if len(sinks) == 0 {
+			return nil, fmt.Errorf("no any sinks in SINKS of conf.yml")
+		}
+		return sinks, nil
+	}
+	var selected []*sinkConfig
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		var found bool
+		for _, v := range sinks {
+			if strings.EqualFold(v.Name, name) {
+				selected = append(selected, v)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("sink %s is not configured in SINKS of conf.yml", name)
+		}
+	}
+	return selected, nil
+}
+
+// forwardEvents sends the events to the sink in batches, and returns the number of sent events
+func forwardEvents(conf *sinkConfig, events []*sinkEvent) (int, error) {
+	s, err := openSink(conf)
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+	size := conf.BatchSize
+	if size <= 0 {
+		size = defaultSinkBatch
+	}
+	var sent int
+	for i := 0; i < len(events); i += size {
+		end := i + size
+		if end > len(events) {
+			end = len(events)
+		}
+		if err = s.Send(events[i:end]); err != nil {
+			return sent, err
+		}
+		sent = end
+	}
+	return sent, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gyyyy/ZoomEye-go/zoomeye"
+)
+
+func TestSinks(t *testing.T) {
+	result := &zoomeye.SearchResult{Type: "host"}
+	if err := json.Unmarshal([]byte(`{"total": 5, "matches": [
+		{"ip": "10.0.0.1", "portinfo": {"port": 80, "service": "http", "app": "nginx"}},
+		{"ip": "10.0.0.6", "portinfo": {"port": 80, "service": "http", "app": "nginx"}},
+		{"ip": "10.0.0.11", "portinfo": {"port": 80, "service": "http", "app": "nginx"}},
+		{"ip": "10.0.0.16", "portinfo": {"port": 443, "service": "https", "app": "nginx"}},
+		{"ip": "10.0.0.21", "portinfo": {"port": 8080, "service": "http", "app": "nginx"}}
+	]}`), result); err != nil {
+		t.FailNow()
+	}
+	matches := make([]finder, len(result.Matches))
+	for i, m := range result.Matches {
+		matches[i] = m
+	}
+	ctx := map[string]interface{}{"type": "host", "dork": "nginx"}
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.FailNow()
+	}
+	defer udp.Close()
+	var (
+		kern, emerg, invalid = 0, 0, 8
+		conf                 = &sinkConfig{Name: "udp", Type: "syslog", Address: udp.LocalAddr().String(), Facility: &kern, Severity: &invalid}
+	)
+	if _, err = forwardEvents(conf, nil); err == nil {
+		t.FailNow()
+	}
+	conf.Severity = &emerg
+	events := sinkEvents(conf, "host", matches, ctx)
+	if n, err := forwardEvents(conf, events); err != nil || n != 5 {
+		t.FailNow()
+	}
+	buf := make([]byte, syslogUDPMax)
+	n, _, err := udp.ReadFrom(buf)
+	if msg := string(buf[:n]); err != nil || !strings.HasPrefix(msg, "<0>1 ") ||
+		!strings.Contains(msg, ` match [zoomeye@32473 `) || !strings.Contains(msg, ` dork="nginx"`) || !strings.Contains(msg, ` ip="10.0.0.1"`) {
+		t.Fail()
+	}
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.FailNow()
+	}
+	defer tcp.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := tcp.Accept()
+		if err != nil {
+			received <- ""
+			return
+		}
+		b, _ := ioutil.ReadAll(conn)
+		conn.Close()
+		received <- string(b)
+	}()
+	conf = &sinkConfig{Name: "tcp", Type: "syslog", Address: "tcp://" + tcp.Addr().String(), BatchSize: 2,
+		Fields: map[string]map[string]string{"host": {"addr": "ip", "port": "portinfo.port"}}}
+	if n, err := forwardEvents(conf, sinkEvents(conf, "host", matches, ctx)); err != nil || n != 5 {
+		t.FailNow()
+	}
+	var frames int
+	for s := <-received; s != ""; frames++ {
+		var size int
+		if _, err := fmt.Sscanf(s, "%d ", &size); err != nil {
+			t.FailNow()
+		}
+		s = s[len(fmt.Sprint(size))+1:]
+		if msg := s[:size]; !strings.HasPrefix(msg, "<14>1 ") || !strings.Contains(msg, ` addr="10.0.0.`) || strings.Contains(msg, ` app=`) {
+			t.FailNow()
+		}
+		s = s[size:]
+	}
+	if frames != 5 {
+		t.Fail()
+	}
+	var (
+		requests int
+		hec      []map[string]interface{}
+	)
+	splunk := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Splunk token" || r.URL.Path != "/services/collector/event" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		requests++
+		dec := json.NewDecoder(r.Body)
+		for dec.More() {
+			var v map[string]interface{}
+			if dec.Decode(&v) != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			hec = append(hec, v)
+		}
+		w.Write([]byte(`{"text":"Success","code":0}`))
+	}))
+	defer splunk.Close()
+	conf = &sinkConfig{Name: "splunk", Type: "splunk", URL: splunk.URL, Token: "token", Index: "zoomeye", BatchSize: 2}
+	if n, err := forwardEvents(conf, sinkEvents(conf, "host", matches, ctx)); err != nil || n != 5 || requests != 3 || len(hec) != 5 {
+		t.FailNow()
+	}
+	if e := hec[0]["event"].(map[string]interface{}); hec[0]["host"] != "10.0.0.1" || hec[0]["index"] != "zoomeye" ||
+		e["dork"] != "nginx" || e["type"] != "host" || e["port"] != float64(80) {
+		t.Fail()
+	}
+	conf.Token = "wrong"
+	if n, err := forwardEvents(conf, sinkEvents(conf, "host", matches, ctx)); err == nil || n != 0 {
+		t.Fail()
+	}
+	if _, err = selectSinks([]*sinkConfig{conf}, "splunk,missing"); err == nil {
+		t.Fail()
+	}
+}
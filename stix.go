@@ -0,0 +1,350 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gyyyy/ZoomEye-go/zoomeye"
+)
+
+var (
+	// stixNamespace is the namespace of deterministic identifiers of STIX cyber-observable objects
+	stixNamespace = [16]byte{0x00, 0xab, 0xed, 0xb4, 0xaa, 0x42, 0x46, 0x6c, 0x9c, 0x01, 0xfe, 0xd2, 0x33, 0x15, 0xa9, 0xb7}
+	// zoomeyeNamespace is the namespace of identifiers of the other objects exported by ZoomEye-go
+	zoomeyeNamespace = [16]byte{0x5f, 0x0c, 0x6e, 0x3a, 0x8d, 0x21, 0x4b, 0x7e, 0x9a, 0x43, 0x1c, 0x67, 0xd0, 0x2e, 0x94, 0x18}
+	stixObservables  = map[string]bool{
+		"ipv4-addr":         true,
+		"ipv6-addr":         true,
+		"domain-name":       true,
+		"autonomous-system": true,
+		"network-traffic":   true,
+		"x509-certificate":  true,
+	}
+)
+
+// uuid5 generates name-based UUID (version 5) in the namespace
+func uuid5(ns [16]byte, name string) string {
+	h := sha1.New()
+	h.Write(ns[:])
+	h.Write([]byte(name))
+	b := h.Sum(nil)[:16]
+	b[6] = b[6]&0x0f | 0x50
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// stixID generates identifier of object from its ID contributing properties
+func stixID(typ string, props map[string]interface{}) string {
+	b, _ := json.Marshal(props)
+	if stixObservables[typ] {
+		return typ + "--" + uuid5(stixNamespace, string(b))
+	}
+	return typ + "--" + uuid5(zoomeyeNamespace, typ+string(b))
+}
+
+func stixTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+// certificate represents the fields parsed from SSL certificate text of match
+type certificate struct {
+	Subject string
+	Issuer  string
+	Serial  string
+	SHA1    string
+	SHA256  string
+}
+
+func parseCertificate(s string) *certificate {
+	var (
+		cert  = &certificate{}
+		found bool
+	)
+	for _, line := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		kv := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		var (
+			k = strings.ToLower(strings.TrimSpace(kv[0]))
+			v = strings.TrimSpace(kv[1])
+		)
+		switch {
+		case v == "":
+			continue
+		case k == "subject":
+			cert.Subject = v
+		case k == "issuer":
+			cert.Issuer = v
+		case k == "serial number" || k == "serial":
+			cert.Serial = v
+		case strings.Contains(k, "sha256") || strings.Contains(k, "sha-256"):
+			cert.SHA256 = strings.ToLower(strings.ReplaceAll(v, ":", ""))
+		case strings.Contains(k, "sha1") || strings.Contains(k, "sha-1"):
+			cert.SHA1 = strings.ToLower(strings.ReplaceAll(v, ":", ""))
+		default:
+			continue
+		}
+		found = true
+	}
+	if !found {
+		return nil
+	}
+	return cert
+}
+
+// observable represents the infrastructure information found in a match
+type observable struct {
+	IPs     []string
+	Port    int
+	Service string
+	App     string
+	Domains []string
+	ASN     int
+	Org     string
+	Time    time.Time
+	Cert    *certificate
+}
+
+func (o *observable) label() string {
+	var parts []string
+	if o.Port > 0 {
+		parts = append(parts, strconv.Itoa(o.Port)+"/"+o.Service)
+	}
+	if o.App != "" {
+		parts = append(parts, o.App)
+	}
+	return strings.Join(parts, " ")
+}
+
+func splitValues(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func observables(result *zoomeye.SearchResult, now time.Time) []*observable {
+	obs := make([]*observable, 0, len(result.Matches))
+	for _, m := range result.Matches {
+		o := &observable{
+			IPs: splitValues(m.FindString("ip")),
+			Org: m.FindString("geoinfo.organization"),
+		}
+		if len(o.IPs) == 0 {
+			continue
+		}
+		if asn, err := strconv.ParseFloat(strings.TrimPrefix(strings.ToUpper(m.FindString("geoinfo.asn")), "AS"), 64); err == nil {
+			o.ASN = int(asn)
+		}
+		if t, ok := zoomeye.ParseTime(m.FindString("timestamp")); ok {
+			o.Time = t
+		} else {
+			o.Time = now
+		}
+		if result.Type == "web" {
+			o.App = withVersion(m.Find("webapp"))
+			o.Domains = splitValues(m.FindString("site") + "," + m.FindString("domains"))
+		} else {
+			o.Port, _ = strconv.Atoi(m.FindString("portinfo.port"))
+			o.Service = m.FindString("portinfo.service")
+			o.App = strings.TrimSpace(m.FindString("portinfo.app") + " " + m.FindString("portinfo.version"))
+			if h := m.FindString("portinfo.hostname"); h != "" && net.ParseIP(h) == nil {
+				o.Domains = []string{h}
+			}
+		}
+		ssl := m.FindString("ssl")
+		if ssl == "" {
+			ssl = m.FindString("portinfo.ssl")
+		}
+		if ssl != "" {
+			o.Cert = parseCertificate(ssl)
+		}
+		seen := make(map[string]bool)
+		domains := o.Domains[:0]
+		for _, d := range o.Domains {
+			if d = strings.ToLower(d); !seen[d] && net.ParseIP(d) == nil {
+				seen[d] = true
+				domains = append(domains, d)
+			}
+		}
+		o.Domains = domains
+		obs = append(obs, o)
+	}
+	return obs
+}
+
+// stixBundle converts the matches into a STIX 2.1 bundle, each match is an observed-data of
+// ipv4-addr/ipv6-addr, network-traffic, domain-name, autonomous-system and x509-certificate,
+// and all of them are consisted of an infrastructure named by the dork
+func stixBundle(result *zoomeye.SearchResult, meta *reportMeta, now time.Time) map[string]interface{} {
+	if meta == nil {
+		meta = &reportMeta{}
+	}
+	var (
+		created  = stixTime(now)
+		identity = map[string]interface{}{
+			"type":           "identity",
+			"spec_version":   "2.1",
+			"id":             stixID("identity", map[string]interface{}{"name": "ZoomEye-go"}),
+			"created":        "2021-01-01T00:00:00.000Z",
+			"modified":       "2021-01-01T00:00:00.000Z",
+			"name":           "ZoomEye-go",
+			"identity_class": "system",
+		}
+		infra = map[string]interface{}{
+			"type":                 "infrastructure",
+			"spec_version":         "2.1",
+			"id":                   stixID("infrastructure", map[string]interface{}{"dork": meta.Dork, "resource": result.Type}),
+			"created_by_ref":       identity["id"],
+			"created":              created,
+			"modified":             created,
+			"name":                 "ZoomEye " + result.Type + " search: " + meta.Dork,
+			"description":          fmt.Sprintf("Infrastructure discovered by ZoomEye %s search of dork %q (%s)", result.Type, meta.Dork, meta.Source),
+			"infrastructure_types": []string{"unknown"},
+			"x_zoomeye_dork":       meta.Dork,
+		}
+		objects = []interface{}{identity, infra}
+		seen    = make(map[string]bool)
+	)
+	add := func(obj map[string]interface{}) string {
+		id := obj["id"].(string)
+		if !seen[id] {
+			seen[id] = true
+			objects = append(objects, obj)
+		}
+		return id
+	}
+	consist := func(ref string) {
+		add(map[string]interface{}{
+			"type":              "relationship",
+			"spec_version":      "2.1",
+			"id":                stixID("relationship", map[string]interface{}{"source": infra["id"], "target": ref}),
+			"created_by_ref":    identity["id"],
+			"created":           created,
+			"modified":          created,
+			"relationship_type": "consists-of",
+			"source_ref":        infra["id"],
+			"target_ref":        ref,
+		})
+	}
+	for _, o := range observables(result, now) {
+		var (
+			refs   []string
+			ipRefs []string
+			asRef  string
+		)
+		if o.ASN > 0 {
+			as := map[string]interface{}{
+				"type":         "autonomous-system",
+				"spec_version": "2.1",
+				"id":           stixID("autonomous-system", map[string]interface{}{"number": o.ASN}),
+				"number":       o.ASN,
+			}
+			if o.Org != "" {
+				as["name"] = o.Org
+			}
+			asRef = add(as)
+			refs = append(refs, asRef)
+		}
+		for _, ip := range o.IPs {
+			typ := "ipv4-addr"
+			if strings.Contains(ip, ":") {
+				typ = "ipv6-addr"
+			}
+			addr := map[string]interface{}{
+				"type":         typ,
+				"spec_version": "2.1",
+				"id":           stixID(typ, map[string]interface{}{"value": ip}),
+				"value":        ip,
+			}
+			if asRef != "" {
+				addr["belongs_to_refs"] = []string{asRef}
+			}
+			ref := add(addr)
+			consist(ref)
+			ipRefs = append(ipRefs, ref)
+			refs = append(refs, ref)
+			if o.Port > 0 {
+				protocols := []string{"tcp"}
+				if s := strings.ToLower(o.Service); s != "" && s != "tcp" {
+					protocols = append(protocols, s)
+				}
+				props := map[string]interface{}{"dst_ref": ref, "dst_port": o.Port, "protocols": protocols}
+				refs = append(refs, add(map[string]interface{}{
+					"type":         "network-traffic",
+					"spec_version": "2.1",
+					"id":           stixID("network-traffic", props),
+					"dst_ref":      ref,
+					"dst_port":     o.Port,
+					"protocols":    protocols,
+				}))
+			}
+		}
+		for _, d := range o.Domains {
+			ref := add(map[string]interface{}{
+				"type":             "domain-name",
+				"spec_version":     "2.1",
+				"id":               stixID("domain-name", map[string]interface{}{"value": d}),
+				"value":            d,
+				"resolves_to_refs": ipRefs,
+			})
+			consist(ref)
+			refs = append(refs, ref)
+		}
+		if c := o.Cert; c != nil {
+			var (
+				cert   = map[string]interface{}{"type": "x509-certificate", "spec_version": "2.1"}
+				hashes = make(map[string]string)
+			)
+			if c.SHA1 != "" {
+				hashes["SHA-1"] = c.SHA1
+			}
+			if c.SHA256 != "" {
+				hashes["SHA-256"] = c.SHA256
+			}
+			for k, v := range map[string]string{"subject": c.Subject, "issuer": c.Issuer, "serial_number": c.Serial} {
+				if v != "" {
+					cert[k] = v
+				}
+			}
+			if len(hashes) > 0 {
+				cert["hashes"] = hashes
+				cert["id"] = stixID("x509-certificate", map[string]interface{}{"hashes": hashes})
+			} else {
+				cert["id"] = stixID("x509-certificate", map[string]interface{}{"serial_number": c.Serial, "subject": c.Subject})
+			}
+			refs = append(refs, add(cert))
+		}
+		observed := map[string]interface{}{
+			"type":            "observed-data",
+			"spec_version":    "2.1",
+			"id":              stixID("observed-data", map[string]interface{}{"refs": refs, "time": o.Time.Unix()}),
+			"created_by_ref":  identity["id"],
+			"created":         created,
+			"modified":        created,
+			"first_observed":  stixTime(o.Time),
+			"last_observed":   stixTime(o.Time),
+			"number_observed": 1,
+			"object_refs":     refs,
+			"x_zoomeye_dork":  meta.Dork,
+		}
+		if l := o.label(); l != "" {
+			observed["labels"] = []string{l}
+		}
+		add(observed)
+	}
+	return map[string]interface{}{
+		"type":    "bundle",
+		"id":      stixID("bundle", map[string]interface{}{"infrastructure": infra["id"], "created": created}),
+		"objects": objects,
+	}
+}
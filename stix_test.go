@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gyyyy/ZoomEye-go/zoomeye"
+)
+
+func TestThreatIntelExport(t *testing.T) {
+	var (
+		host = &zoomeye.SearchResult{Type: "host"}
+		web  = &zoomeye.SearchResult{Type: "web"}
+	)
+	if err := json.Unmarshal([]byte(`{"total": 2, "matches": [
+		{"ip": "10.0.0.1", "timestamp": "2021-02-01T10:00:00", "portinfo": {"port": 443, "service": "https", "app": "nginx"},
+			"ssl": "SSL Certificate\nSubject: CN=example.com\nIssuer: CN=R3\nSHA1 Fingerprint: AB:CD:EF"},
+		{"ip": "10.0.0.6", "timestamp": "2021-02-01T11:00:00", "portinfo": {"port": 80, "service": "http", "app": "nginx"}}
+	]}`), host); err != nil {
+		t.FailNow()
+	}
+	if err := json.Unmarshal([]byte(`{"total": 2, "matches": [
+		{"ip": ["172.16.0.1"], "site": "www.example0.com", "domains": ["WWW.example0.com"], "webapp": [{"name": "WordPress", "version": "5.6"}]},
+		{"ip": ["172.16.0.2"], "site": "www.example1.com", "domains": ["172.16.0.2"], "webapp": [{"name": "WordPress"}]}
+	]}`), web); err != nil {
+		t.FailNow()
+	}
+	var (
+		meta   = &reportMeta{Dork: "nginx", Source: "search"}
+		now    = time.Date(2021, 3, 2, 0, 0, 0, 0, time.UTC)
+		bundle = stixBundle(host, meta, now)
+		types  = make(map[string]int)
+		ip     map[string]interface{}
+	)
+	for _, o := range bundle["objects"].([]interface{}) {
+		obj := o.(map[string]interface{})
+		types[obj["type"].(string)]++
+		if obj["type"] == "ipv4-addr" && obj["value"] == "10.0.0.1" {
+			ip = obj
+		}
+	}
+	if types["infrastructure"] != 1 || types["observed-data"] != 2 || types["network-traffic"] != 2 || types["x509-certificate"] != 1 {
+		t.Fail()
+	}
+	if ip == nil || ip["id"] != "ipv4-addr--7dd44d27-f473-5ba9-b12b-0d3a61bbed2e" {
+		t.Fail()
+	}
+	event := mispEvent(host, meta, now)["Event"].(map[string]interface{})
+	if event["info"] != "ZoomEye host search: nginx" || len(event["Attribute"].([]interface{})) == 0 {
+		t.Fail()
+	}
+	var found bool
+	for _, a := range event["Attribute"].([]interface{}) {
+		a := a.(map[string]interface{})
+		if a["type"] == "x509-fingerprint-sha1" && a["value"] == "abcdef" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fail()
+	}
+	obs := observables(web, now)
+	if len(obs) != 2 || len(obs[0].Domains) != 1 || obs[0].Domains[0] != "www.example0.com" || obs[0].Port != 0 {
+		t.Fail()
+	}
+	if len(obs[1].Domains) != 1 || !obs[1].Time.Equal(now) {
+		t.Fail()
+	}
+}
@@ -0,0 +1,487 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	// mapCols and mapRows are the size of world map in terminal, each cell is 3.6° of longitude
+	mapCols = 100
+	mapRows = 28
+	// mapTop and mapBottom are the latitudes of the top and bottom edges of world map
+	mapTop    = 84.0
+	mapBottom = -58.0
+	// mapLevels is the number of color levels of countries
+	mapLevels = 4
+)
+
+var (
+	// mapColors are the colors of levels from the fewest to the most matches
+	mapColors = []string{colorLightBlue, colorLightCyan, colorLightYellow, colorLightRed}
+	// mapChars are the characters of levels from the fewest to the most matches
+	mapChars = []string{"░", "▒", "▓", "█"}
+	// landPolygons are the coarse outlines (lon, lat) of continents and major islands
+	landPolygons = [][][2]float64{
+		// North America
+		{{-168, 66}, {-165, 69}, {-156, 71.3}, {-141, 69.6}, {-128, 70}, {-115, 68.5}, {-100, 68}, {-90, 69.5}, {-82, 67},
+			{-78, 63}, {-70, 61}, {-64, 60}, {-60, 55}, {-56, 52}, {-60, 45.5}, {-66, 44}, {-70, 42}, {-74, 40.5}, {-76, 35},
+			{-80, 31}, {-80, 25.5}, {-82, 27}, {-84, 30}, {-89, 30}, {-94, 29.5}, {-97, 26}, {-97.5, 22}, {-95, 19}, {-91, 19},
+			{-87, 21.5}, {-88, 16}, {-83, 15}, {-83, 10}, {-77.5, 8.5}, {-80, 7}, {-86, 11}, {-92, 14.5}, {-97, 16}, {-105, 20},
+			{-110, 23}, {-115, 30}, {-117, 32.5}, {-121, 35}, {-124, 40}, {-124, 48}, {-127, 50.5}, {-131, 54}, {-136, 58},
+			{-141, 60}, {-148, 60.5}, {-152, 58}, {-158, 56}, {-164, 54.5}, {-158, 58.5}, {-162, 60}, {-165, 62.5}, {-164, 64.5}},
+		// Canadian Arctic Archipelago
+		{{-120, 71}, {-110, 77}, {-95, 80}, {-75, 83}, {-62, 82}, {-70, 76}, {-78, 72}, {-62, 67}, {-68, 62.5}, {-80, 64},
+			{-88, 68}, {-100, 70}, {-115, 69}},
+		// Greenland
+		{{-73, 78}, {-60, 82}, {-30, 83.5}, {-18, 80}, {-20, 75}, {-22, 70}, {-30, 68}, {-40, 65}, {-43, 60}, {-48, 61},
+			{-52, 65}, {-54, 69}, {-58, 75}},
+		// Newfoundland, Cuba, Hispaniola and Iceland
+		{{-59.4, 47.6}, {-55.5, 51.6}, {-52.6, 47.5}, {-55.9, 46.8}},
+		{{-85, 22}, {-80, 23}, {-74, 20}, {-77, 20}},
+		{{-74.5, 18.4}, {-72.7, 19.9}, {-69.2, 19}, {-68.3, 18.6}, {-71, 17.7}, {-74.4, 18.2}},
+		{{-24, 65.5}, {-22, 66.5}, {-14, 66.5}, {-13.5, 65}, {-18, 63.4}, {-22, 63.8}},
+		// South America
+		{{-77.5, 8.5}, {-72, 12}, {-62, 10.5}, {-60, 8.5}, {-52, 5}, {-50, 0}, {-44, -2.5}, {-35, -5}, {-35, -9}, {-39, -13},
+			{-39, -18}, {-41, -22}, {-48, -26}, {-53, -34}, {-58, -38.5}, {-62, -39}, {-65, -42}, {-67, -46}, {-69, -50.5},
+			{-68.5, -53}, {-66, -55}, {-70, -55}, {-74, -52}, {-75, -46}, {-73.5, -40}, {-72, -33}, {-71, -28}, {-70.3, -18.5},
+			{-75.5, -15}, {-79, -8}, {-81, -5}, {-80, -1}, {-80, 2}, {-78, 7}},
+		// Africa
+		{{-6, 35.8}, {-10, 30}, {-13, 27.5}, {-17, 21}, {-17, 14.7}, {-15, 11}, {-13, 8}, {-8, 4.5}, {-2, 5}, {3, 6.3}, {8, 4.5},
+			{9.5, 3.5}, {9.5, -1}, {12, -5}, {13.5, -11}, {12, -17}, {14.5, -23}, {16.5, -28.5}, {18.5, -34}, {20, -34.8},
+			{25.5, -34}, {30, -31}, {32.5, -28.5}, {32.8, -26}, {35.5, -24}, {35, -20}, {40.5, -15.5}, {40.5, -10.5}, {39.5, -6},
+			{40.5, -2.5}, {43, 0}, {47.5, 4.5}, {51, 10.5}, {51.3, 12}, {45, 10.5}, {43.3, 12.5}, {39.5, 15.5}, {37.3, 21},
+			{35.6, 24}, {33.5, 27.5}, {32.5, 30}, {32.3, 31.3}, {29, 30.9}, {25, 31.7}, {20, 30.8}, {19.8, 32.2}, {15, 32.3},
+			{11, 33.2}, {10.5, 36.8}, {9.5, 37.3}, {3, 36.8}, {-2, 35.1}},
+		{{49.3, -12}, {50.5, -15.5}, {47, -25}, {45, -25.5}, {43.5, -22}, {44.5, -16}},
+		// Eurasia
+		{{-5.6, 36}, {-9, 37}, {-9.5, 43}, {-8, 43.7}, {-1.5, 43.4}, {-1.2, 46}, {-4.7, 48}, {-1.5, 49.7}, {1.8, 51}, {5, 53.3},
+			{8, 53.5}, {8.5, 57}, {10.5, 57.7}, {10.5, 55}, {12, 54.3}, {14, 54}, {18, 54.8}, {21, 55.5}, {21, 57}, {24, 57.5},
+			{23.5, 59.3}, {28, 59.5}, {30, 60}, {33, 66}, {40, 66.5}, {44, 68.5}, {53, 68}, {60, 69.5}, {68, 69}, {73, 73},
+			{80, 72.5}, {87, 75}, {100, 77}, {105, 77.5}, {113, 74}, {129, 72}, {140, 72.5}, {150, 71.5}, {160, 70}, {170, 70},
+			{180, 69}, {180, 65}, {172, 61}, {163, 60}, {162, 56}, {156, 51}, {156, 57}, {142, 59}, {137, 54.5}, {141, 53},
+			{140, 48}, {135, 43}, {131, 42.5}, {129, 40.5}, {129.5, 35.2}, {126.5, 34.5}, {126.5, 37.5}, {125, 39.5},
+			{121.5, 39}, {122, 40.5}, {118, 39}, {119, 37}, {122.5, 37.3}, {120, 36}, {119.5, 34.5}, {121.5, 31.5}, {122, 29},
+			{119.5, 25.5}, {116.5, 23}, {113, 22}, {110, 21}, {108, 21.5}, {106, 20}, {105.5, 18.5}, {108.5, 15}, {109, 11.5},
+			{105, 8.6}, {104.8, 10.5}, {101, 12.7}, {100, 13.5}, {99, 10}, {100, 7}, {103.5, 1.3}, {101.5, 3}, {98, 8},
+			{98.5, 13}, {97.5, 16.5}, {94.5, 16}, {94, 19.5}, {92, 21.5}, {90, 22}, {87, 21.5}, {86.5, 20}, {82, 16.5},
+			{80.2, 13}, {79.8, 10.3}, {77.5, 8}, {76.3, 9.5}, {73, 17}, {72.7, 21}, {70, 20.8}, {68.5, 23.5}, {66.5, 25.4},
+			{61.5, 25.2}, {57, 25.8}, {56.4, 27.1}, {54, 26.5}, {51.5, 27.9}, {50, 30}, {48, 30}, {48.5, 28}, {50.5, 26},
+			{51.5, 24}, {54.5, 24.2}, {56, 26}, {56.4, 24.5}, {59.8, 22.5}, {57.7, 19}, {55, 17}, {52, 16}, {48, 14},
+			{43.5, 12.7}, {42.7, 16.5}, {39, 21.5}, {35, 28}, {34.9, 29.5}, {34.2, 31.3}, {35, 33}, {36, 34.7}, {36, 36.8},
+			{32, 36.2}, {30, 36.2}, {27.3, 37}, {26, 40}, {23, 40.5}, {24, 38}, {22.5, 36.5}, {21, 38.5}, {19.5, 41.5},
+			{19, 42.5}, {13.5, 45.5}, {12.3, 44.5}, {16, 41.5}, {18.5, 40.2}, {16, 38}, {15.6, 40}, {12, 42}, {10, 44},
+			{7.5, 43.8}, {3.2, 43.2}, {3.2, 42}, {0.8, 41}, {-0.3, 39.5}, {0, 38.7}, {-2, 36.7}},
+		// Scandinavia
+		{{5, 58}, {5, 62}, {10, 64}, {14, 67.5}, {18, 69.5}, {25, 71}, {31, 70}, {28.5, 68}, {30, 65}, {30, 61}, {23, 60},
+			{21.5, 60.7}, {21.5, 63.5}, {25, 65}, {24, 65.8}, {21, 64.5}, {17.5, 62.5}, {17.5, 60.5}, {19, 59.5}, {16.5, 57},
+			{14.5, 56}, {13, 55.5}, {12.5, 56.5}, {11, 58.8}, {8, 58}},
+		// Great Britain, Ireland, Sicily, Svalbard and Novaya Zemlya
+		{{-5.7, 50}, {1.5, 51.2}, {1.7, 52.7}, {0, 53.5}, {-1.6, 55.6}, {-2, 57.6}, {-3.5, 58.6}, {-5, 58.6}, {-6.2, 56.5},
+			{-5, 55}, {-3, 54}, {-3, 53.3}, {-4.7, 52.8}, {-5.3, 51.7}, {-3, 51.4}},
+		{{-10, 51.6}, {-6, 52}, {-6, 53.9}, {-7.3, 55.3}, {-10, 54.2}},
+		{{12.4, 38}, {15.6, 38.2}, {15.1, 36.7}, {12.5, 37.6}},
+		{{11, 78.5}, {17, 80}, {27, 80}, {22, 77.5}, {16, 76.5}},
+		{{52, 71.5}, {56, 74.5}, {65, 76.5}, {68, 76.5}, {58, 73}, {56, 71}, {53, 70.8}},
+		// Japan, Sakhalin, Taiwan, Hainan, Sri Lanka and Philippines
+		{{130, 31}, {131.5, 31.5}, {132, 34}, {135, 33.5}, {137, 34.5}, {139.8, 35}, {140.8, 36}, {141, 38}, {142, 39.5},
+			{141.5, 41.5}, {140, 41}, {139.8, 40}, {139.5, 38}, {137, 37}, {136, 36}, {133, 35.5}, {131, 34.5}, {130, 33.5}},
+		{{140, 41.5}, {141.5, 42.5}, {143.5, 42}, {145.5, 43.3}, {144, 44.2}, {141.8, 45.5}, {141.5, 43.5}, {140, 42.5}},
+		{{142, 46}, {143.5, 46.5}, {144.5, 49}, {143, 54}, {142, 53.5}},
+		{{120.2, 22.5}, {120.8, 21.9}, {121.9, 24.8}, {121.5, 25.3}, {120.1, 23.7}},
+		{{108.6, 19.2}, {110, 20.1}, {111, 19.6}, {109.6, 18.2}},
+		{{79.8, 6}, {81.8, 7}, {81, 9.5}, {79.9, 9.8}},
+		{{120, 14.5}, {120.6, 18.5}, {122.3, 18.5}, {122, 16}, {124, 13}, {121, 13.8}},
+		{{122, 7}, {126.5, 6}, {126.3, 9.5}, {123.5, 8.5}},
+		// Indonesia and New Guinea
+		{{109, 1.5}, {111, -3}, {114, -4}, {116.5, -3.5}, {118, 1}, {119, 5}, {117, 7}, {115, 5}, {111.5, 2.5}},
+		{{95.3, 5.6}, {98, 4}, {104, -1}, {106, -3}, {105.8, -5.9}, {104.5, -5.8}, {101, -2.3}, {98.5, 1.5}},
+		{{105.2, -6.8}, {108, -6.2}, {112.5, -6.8}, {114.4, -7.7}, {110.5, -8.2}, {106, -7.6}},
+		{{119.5, -5.5}, {120.5, -3}, {120.5, 0.5}, {124, 1}, {123, -1}, {121, -1.5}, {122, -4.5}, {120.5, -5.6}},
+		{{131, -1.3}, {135, -3.3}, {138, -1.6}, {144, -3.8}, {147.5, -6}, {150, -10.5}, {146, -8}, {142, -9.2}, {138, -8.3},
+			{137.5, -5}, {133, -4}},
+		// Australia and New Zealand
+		{{113.5, -22}, {114, -26}, {115, -34}, {117.5, -35}, {123, -33.8}, {126, -32.3}, {131, -31.5}, {134, -32.5},
+			{137.5, -35.5}, {140, -38}, {144, -38.3}, {146.5, -39}, {150, -37.5}, {151.3, -33.8}, {153.5, -28}, {153, -25},
+			{150.5, -22.5}, {146, -19}, {145.3, -15}, {143.5, -14}, {142.5, -10.8}, {141.5, -13.5}, {141.6, -17}, {140.5, -17.5},
+			{139, -17}, {136, -15}, {137, -12}, {136.5, -11.8}, {132.5, -11.5}, {130, -13}, {129.3, -15}, {126, -14},
+			{122.5, -17}, {121.5, -19}, {117, -20.7}},
+		{{144.6, -40.7}, {148.3, -40.9}, {148, -43.2}, {146, -43.6}, {145, -42}},
+		{{172.7, -34.4}, {174.5, -36}, {176, -37.6}, {178.5, -37.7}, {177.5, -39.3}, {176, -41.3}, {174.8, -41.3},
+			{174.5, -39.5}, {173.8, -39.2}, {174.5, -38}},
+		{{172.7, -40.5}, {174.3, -41.7}, {173, -43.5}, {171, -45}, {169, -46.6}, {166.5, -46}, {168, -44}, {172, -40.5}},
+	}
+	// waterPolygons are the inland seas cut from the continents
+	waterPolygons = [][][2]float64{
+		{{-95, 60}, {-94, 58.5}, {-88, 56.5}, {-82, 55}, {-80, 51.5}, {-79, 55}, {-77, 60}, {-78, 62.5}, {-85, 64}, {-90, 64}},
+		{{27.5, 42}, {28, 45}, {30.5, 46.5}, {33, 46}, {36.5, 45.3}, {39, 47}, {38, 45}, {41.5, 41.5}, {36, 41.7}, {32, 41.8},
+			{29, 41.2}},
+		{{47, 45}, {49.5, 46.5}, {53, 46.8}, {53, 45}, {51, 44.5}, {53, 42}, {54, 40}, {53.5, 37.5}, {51, 36.7}, {49, 38},
+			{49.5, 40.5}, {47.5, 42.5}},
+	}
+)
+
+// mapCountry represents a country on world map, the land cells are assigned to the first country
+// whose boxes (min lon, min lat, max lon, max lat) contain them, so the smaller countries are in front
+type mapCountry struct {
+	Code  string
+	Names []string
+	Boxes [][4]float64
+}
+
+var mapCountries = []*mapCountry{
+	{"SG", []string{"Singapore", "新加坡"}, [][4]float64{{103.6, 1.15, 104.1, 1.5}}},
+	{"HK", []string{"Hong Kong", "香港"}, [][4]float64{{113.8, 22.15, 114.4, 22.6}}},
+	{"TW", []string{"Taiwan", "台湾", "中国台湾"}, [][4]float64{{119.9, 21.9, 122, 25.3}}},
+	{"IL", []string{"Israel", "以色列"}, [][4]float64{{34.3, 29.5, 35.9, 33.3}}},
+	{"BE", []string{"Belgium", "比利时"}, [][4]float64{{2.5, 49.5, 6.4, 51.5}}},
+	{"NL", []string{"Netherlands", "The Netherlands", "荷兰"}, [][4]float64{{3.3, 50.8, 7.2, 53.6}}},
+	{"CH", []string{"Switzerland", "瑞士"}, [][4]float64{{5.9, 45.8, 10.5, 47.8}}},
+	{"AT", []string{"Austria", "奥地利"}, [][4]float64{{9.5, 46.4, 17.2, 49}}},
+	{"CZ", []string{"Czechia", "Czech Republic", "捷克"}, [][4]float64{{12, 48.5, 18.9, 51.1}}},
+	{"SK", []string{"Slovakia", "斯洛伐克"}, [][4]float64{{16.8, 47.7, 22.6, 49.6}}},
+	{"HU", []string{"Hungary", "匈牙利"}, [][4]float64{{16.1, 45.7, 22.9, 48.6}}},
+	{"DK", []string{"Denmark", "丹麦"}, [][4]float64{{8, 54.5, 12.7, 57.8}}},
+	{"PT", []string{"Portugal", "葡萄牙"}, [][4]float64{{-9.5, 37, -6.2, 42.2}}},
+	{"IE", []string{"Ireland", "爱尔兰"}, [][4]float64{{-10.5, 51.4, -6, 55.4}}},
+	{"GB", []string{"United Kingdom", "UK", "Great Britain", "England", "英国"}, [][4]float64{{-8.2, 49.9, 1.8, 60.9}}},
+	{"RS", []string{"Serbia", "塞尔维亚"}, [][4]float64{{18.8, 42.2, 23, 46.2}}},
+	{"BG", []string{"Bulgaria", "保加利亚"}, [][4]float64{{22.3, 41.2, 28.6, 44.2}}},
+	{"GR", []string{"Greece", "希腊"}, [][4]float64{{19.4, 34.8, 28.3, 41.8}}},
+	{"RO", []string{"Romania", "罗马尼亚"}, [][4]float64{{20.2, 43.6, 29.7, 48.3}}},
+	{"LT", []string{"Lithuania", "立陶宛"}, [][4]float64{{21, 53.9, 26.8, 56.5}}},
+	{"LV", []string{"Latvia", "拉脱维亚"}, [][4]float64{{21, 55.7, 28.2, 58.1}}},
+	{"EE", []string{"Estonia", "爱沙尼亚"}, [][4]float64{{21.8, 57.5, 28.2, 59.7}}},
+	{"BY", []string{"Belarus", "白俄罗斯"}, [][4]float64{{23.2, 51.3, 32.8, 56.2}}},
+	{"IT", []string{"Italy", "意大利"}, [][4]float64{{6.6, 36.6, 18.5, 47.1}}},
+	{"DE", []string{"Germany", "德国"}, [][4]float64{{5.9, 47.3, 15, 55.1}}},
+	{"PL", []string{"Poland", "波兰"}, [][4]float64{{14.1, 49, 24.2, 54.9}}},
+	{"UA", []string{"Ukraine", "乌克兰"}, [][4]float64{{22.1, 44.4, 40.2, 52.4}}},
+	{"FR", []string{"France", "法国"}, [][4]float64{{-5.1, 42.3, 8.2, 51.1}}},
+	{"ES", []string{"Spain", "西班牙"}, [][4]float64{{-9.3, 36, 3.3, 43.8}}},
+	{"FI", []string{"Finland", "芬兰"}, [][4]float64{{20.6, 59.8, 31.6, 70.1}}},
+	{"SE", []string{"Sweden", "瑞典"}, [][4]float64{{11, 55.3, 24.2, 69.1}}},
+	{"NO", []string{"Norway", "挪威"}, [][4]float64{{4.6, 58, 31.1, 71.2}, {10, 76, 33, 81}}},
+	{"IS", []string{"Iceland", "冰岛"}, [][4]float64{{-24.5, 63.3, -13.5, 66.6}}},
+	{"GE", []string{"Georgia", "格鲁吉亚"}, [][4]float64{{40, 41, 46.7, 43.6}}},
+	{"AM", []string{"Armenia", "亚美尼亚"}, [][4]float64{{43.4, 38.8, 46.6, 41.3}}},
+	{"AZ", []string{"Azerbaijan", "阿塞拜疆"}, [][4]float64{{44.8, 38.4, 50.4, 41.9}}},
+	{"TR", []string{"Turkey", "Türkiye", "土耳其"}, [][4]float64{{26, 36, 44.8, 42.1}}},
+	{"SY", []string{"Syria", "Syrian Arab Republic", "叙利亚"}, [][4]float64{{35.7, 32.3, 42.4, 37.3}}},
+	{"IQ", []string{"Iraq", "伊拉克"}, [][4]float64{{38.8, 29.1, 48.6, 37.4}}},
+	{"AE", []string{"United Arab Emirates", "UAE", "阿联酋", "阿拉伯联合酋长国"}, [][4]float64{{51.6, 22.6, 56.4, 26.1}}},
+	{"OM", []string{"Oman", "阿曼"}, [][4]float64{{52, 16.6, 59.8, 26.4}}},
+	{"YE", []string{"Yemen", "也门"}, [][4]float64{{42.6, 12.1, 53.1, 19}}},
+	{"EG", []string{"Egypt", "埃及"}, [][4]float64{{24.7, 22, 36.9, 31.7}}},
+	{"SA", []string{"Saudi Arabia", "沙特阿拉伯"}, [][4]float64{{34.5, 16.4, 55.7, 32.2}}},
+	{"IR", []string{"Iran", "Iran, Islamic Republic of", "伊朗"}, [][4]float64{{44, 25, 63.3, 39.8}}},
+	{"TM", []string{"Turkmenistan", "土库曼斯坦"}, [][4]float64{{52.5, 35.1, 66.7, 42.8}}},
+	{"TJ", []string{"Tajikistan", "塔吉克斯坦"}, [][4]float64{{67.3, 36.7, 75.2, 41}}},
+	{"KG", []string{"Kyrgyzstan", "吉尔吉斯斯坦"}, [][4]float64{{69.2, 39.2, 80.3, 43.3}}},
+	{"AF", []string{"Afghanistan", "阿富汗"}, [][4]float64{{60.5, 29.4, 74.9, 38.5}}},
+	{"PK", []string{"Pakistan", "巴基斯坦"}, [][4]float64{{60.9, 23.7, 77.8, 37.1}}},
+	{"UZ", []string{"Uzbekistan", "乌兹别克斯坦"}, [][4]float64{{56, 37.2, 73.1, 45.6}}},
+	{"KZ", []string{"Kazakhstan", "哈萨克斯坦"}, [][4]float64{{46.5, 40.6, 80, 55.4}}},
+	{"MN", []string{"Mongolia", "蒙古"}, [][4]float64{{87.7, 41.6, 119.9, 52.2}}},
+	{"NP", []string{"Nepal", "尼泊尔"}, [][4]float64{{80, 26.3, 88.2, 30.5}}},
+	{"BD", []string{"Bangladesh", "孟加拉国"}, [][4]float64{{88, 20.7, 92.7, 26.6}}},
+	{"LK", []string{"Sri Lanka", "斯里兰卡"}, [][4]float64{{79.6, 5.9, 81.9, 9.9}}},
+	{"IN", []string{"India", "印度"}, [][4]float64{{68.1, 6.7, 89, 30}, {89, 21.9, 97.4, 29.5}, {73.5, 30, 80, 35.5}}},
+	{"KH", []string{"Cambodia", "柬埔寨"}, [][4]float64{{102.3, 10.4, 107.6, 14.7}}},
+	{"LA", []string{"Laos", "Lao People's Democratic Republic", "老挝"}, [][4]float64{{100, 13.9, 107.7, 22.5}}},
+	{"TH", []string{"Thailand", "泰国"}, [][4]float64{{97.3, 5.6, 105.6, 20.5}}},
+	{"VN", []string{"Vietnam", "Viet Nam", "越南"}, [][4]float64{{102.1, 8.2, 109.5, 23.4}}},
+	{"MM", []string{"Myanmar", "缅甸"}, [][4]float64{{92.2, 9.8, 101.2, 28.5}}},
+	{"MY", []string{"Malaysia", "马来西亚"}, [][4]float64{{99.6, 0.8, 119.3, 7.4}}},
+	{"PH", []string{"Philippines", "菲律宾"}, [][4]float64{{116.9, 4.6, 126.6, 21.1}}},
+	{"PG", []string{"Papua New Guinea", "巴布亚新几内亚"}, [][4]float64{{141, -11.7, 156, -1}}},
+	{"ID", []string{"Indonesia", "印度尼西亚", "印尼"}, [][4]float64{{95, -11, 141, 6}}},
+	{"KR", []string{"South Korea", "Korea", "Republic of Korea", "Korea, Republic of", "韩国"}, [][4]float64{{125.9, 33.1, 129.6, 38.6}}},
+	{"KP", []string{"North Korea", "Korea, Democratic People's Republic of", "朝鲜"}, [][4]float64{{124.2, 37.7, 130.7, 43}}},
+	{"JP", []string{"Japan", "日本"}, [][4]float64{{129.4, 31, 145.8, 45.6}}},
+	{"CN", []string{"China", "中国"}, [][4]float64{{73.5, 18, 123, 41}, {80, 41, 96, 49}, {111, 41, 126, 46}, {119, 46, 127, 53.5},
+		{126, 42, 134.8, 48.5}}},
+	{"RU", []string{"Russia", "Russian Federation", "俄罗斯"}, [][4]float64{{27, 41, 180, 82}, {-180, 64, -168, 72}}},
+	{"TN", []string{"Tunisia", "突尼斯"}, [][4]float64{{7.5, 30.2, 11.6, 37.5}}},
+	{"GH", []string{"Ghana", "加纳"}, [][4]float64{{-3.3, 4.7, 1.2, 11.2}}},
+	{"SN", []string{"Senegal", "塞内加尔"}, [][4]float64{{-17.5, 12.3, -11.4, 16.7}}},
+	{"ZW", []string{"Zimbabwe", "津巴布韦"}, [][4]float64{{25.2, -22.4, 33.1, -15.6}}},
+	{"CM", []string{"Cameroon", "喀麦隆"}, [][4]float64{{8.5, 1.7, 16.2, 13.1}}},
+	{"KE", []string{"Kenya", "肯尼亚"}, [][4]float64{{33.9, -4.7, 41.9, 5.5}}},
+	{"BW", []string{"Botswana", "博茨瓦纳"}, [][4]float64{{20, -26.9, 29.4, -17.8}}},
+	{"ZM", []string{"Zambia", "赞比亚"}, [][4]float64{{22, -18.1, 33.7, -8.2}}},
+	{"MZ", []string{"Mozambique", "莫桑比克"}, [][4]float64{{30.2, -26.9, 40.8, -10.5}}},
+	{"TZ", []string{"Tanzania", "Tanzania, United Republic of", "坦桑尼亚"}, [][4]float64{{29.3, -11.7, 40.4, -1}}},
+	{"NA", []string{"Namibia", "纳米比亚"}, [][4]float64{{11.7, -29, 25.3, -16.9}}},
+	{"SO", []string{"Somalia", "索马里"}, [][4]float64{{41, -1.7, 51.4, 12}}},
+	{"ET", []string{"Ethiopia", "埃塞俄比亚"}, [][4]float64{{33, 3.4, 48, 14.9}}},
+	{"NG", []string{"Nigeria", "尼日利亚"}, [][4]float64{{2.7, 4.3, 14.7, 13.9}}},
+	{"AO", []string{"Angola", "安哥拉"}, [][4]float64{{11.7, -18, 24.1, -4.4}}},
+	{"MA", []string{"Morocco", "摩洛哥"}, [][4]float64{{-17, 21, -1, 35.9}}},
+	{"MR", []string{"Mauritania", "毛里塔尼亚"}, [][4]float64{{-17.1, 14.7, -4.8, 27.3}}},
+	{"ML", []string{"Mali", "马里"}, [][4]float64{{-12.2, 10.2, 4.3, 25}}},
+	{"NE", []string{"Niger", "尼日尔"}, [][4]float64{{0.2, 11.7, 16, 23.5}}},
+	{"TD", []string{"Chad", "乍得"}, [][4]float64{{13.5, 7.4, 24, 23.5}}},
+	{"LY", []string{"Libya", "利比亚"}, [][4]float64{{9.3, 19.5, 25.2, 33.2}}},
+	{"SD", []string{"Sudan", "苏丹"}, [][4]float64{{21.8, 8.7, 38.6, 22}}},
+	{"DZ", []string{"Algeria", "阿尔及利亚"}, [][4]float64{{-8.7, 19, 12, 37.1}}},
+	{"CD", []string{"DR Congo", "Democratic Republic of the Congo", "Congo, The Democratic Republic of the", "刚果（金）", "刚果民主共和国"},
+		[][4]float64{{12.2, -13.5, 31.3, 5.4}}},
+	{"ZA", []string{"South Africa", "南非"}, [][4]float64{{16.4, -34.9, 32.9, -22.1}}},
+	{"MG", []string{"Madagascar", "马达加斯加"}, [][4]float64{{43.2, -25.6, 50.5, -11.9}}},
+	{"CU", []string{"Cuba", "古巴"}, [][4]float64{{-85, 19.8, -74.1, 23.3}}},
+	{"PA", []string{"Panama", "巴拿马"}, [][4]float64{{-83, 7.2, -77.2, 9.6}}},
+	{"CR", []string{"Costa Rica", "哥斯达黎加"}, [][4]float64{{-85.9, 8, -82.6, 11.2}}},
+	{"GT", []string{"Guatemala", "危地马拉"}, [][4]float64{{-92.3, 13.7, -88.2, 17.8}}},
+	{"US", []string{"United States", "United States of America", "USA", "美国"}, [][4]float64{{-125, 32.5, -95, 49.4},
+		{-95, 30, -67, 45}, {-106.6, 25.8, -93.5, 32.5}, {-83, 24.5, -80, 31}, {-168, 51, -141, 71.5}, {-160.3, 18.9, -154.8, 22.2}}},
+	{"MX", []string{"Mexico", "墨西哥"}, [][4]float64{{-117.1, 14.5, -86.7, 32.7}}},
+	{"GL", []string{"Greenland", "格陵兰"}, [][4]float64{{-60, 59.7, -11, 83.7}, {-73, 75, -60, 80}}},
+	{"CA", []string{"Canada", "加拿大"}, [][4]float64{{-141, 41.7, -52.6, 83.1}}},
+	{"EC", []string{"Ecuador", "厄瓜多尔"}, [][4]float64{{-81.1, -5, -75.2, 1.5}}},
+	{"UY", []string{"Uruguay", "乌拉圭"}, [][4]float64{{-58.5, -35, -53.1, -30.1}}},
+	{"PY", []string{"Paraguay", "巴拉圭"}, [][4]float64{{-62.7, -27.6, -54.3, -19.3}}},
+	{"CO", []string{"Colombia", "哥伦比亚"}, [][4]float64{{-79, -4.2, -67, 7}, {-76, 7, -71.5, 12.5}}},
+	{"VE", []string{"Venezuela", "委内瑞拉"}, [][4]float64{{-73.4, 0.6, -59.8, 12.2}}},
+	{"PE", []string{"Peru", "秘鲁"}, [][4]float64{{-81.4, -18.4, -68.7, -0.1}}},
+	{"BO", []string{"Bolivia", "玻利维亚"}, [][4]float64{{-69.6, -22.9, -57.5, -9.7}}},
+	{"CL", []string{"Chile", "智利"}, [][4]float64{{-75.7, -56, -71.5, -17.5}, {-71.5, -27, -68, -17.5}}},
+	{"AR", []string{"Argentina", "阿根廷"}, [][4]float64{{-73.6, -55.1, -53.6, -21.8}}},
+	{"BR", []string{"Brazil", "巴西"}, [][4]float64{{-74, -33.8, -34.8, 5.3}}},
+	{"NZ", []string{"New Zealand", "新西兰"}, [][4]float64{{166, -47.5, 178.6, -34.3}}},
+	{"AU", []string{"Australia", "澳大利亚"}, [][4]float64{{112.9, -43.7, 153.7, -10.6}}},
+}
+
+var (
+	// mapGrid is the index of country in mapCountries of each cell, -1 is land of unknown country and -2 is water
+	mapGrid [][]int
+	// mapIndex is the index of country in mapCountries by its lower case name or code
+	mapIndex map[string]int
+)
+
+func inPolygon(lon, lat float64, polygon [][2]float64) bool {
+	var in bool
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[i], polygon[j]
+		if (a[1] > lat) != (b[1] > lat) && lon < (b[0]-a[0])*(lat-a[1])/(b[1]-a[1])+a[0] {
+			in = !in
+		}
+	}
+	return in
+}
+
+func isLand(lon, lat float64) bool {
+	for _, p := range waterPolygons {
+		if inPolygon(lon, lat, p) {
+			return false
+		}
+	}
+	for _, p := range landPolygons {
+		if inPolygon(lon, lat, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// initMap rasterizes the land polygons, a cell is land if at least 2 of its 9 sample points are on land,
+// and assigns each land cell to a country, the cell outside of all boxes is assigned to the country with the nearest box
+func initMap() {
+	if mapGrid != nil {
+		return
+	}
+	mapIndex = make(map[string]int)
+	for i, c := range mapCountries {
+		mapIndex[strings.ToLower(c.Code)] = i
+		for _, name := range c.Names {
+			mapIndex[strings.ToLower(name)] = i
+		}
+	}
+	mapGrid = make([][]int, mapRows)
+	for y := range mapGrid {
+		mapGrid[y] = make([]int, mapCols)
+		for x := range mapGrid[y] {
+			var (
+				lat, lon float64
+				land     int
+			)
+			for _, d := range [][2]float64{{0.5, 0.5}, {0.2, 0.2}, {0.8, 0.2}, {0.2, 0.8}, {0.8, 0.8}, {0.5, 0.2}, {0.5, 0.8}, {0.2, 0.5}, {0.8, 0.5}} {
+				sLat := mapTop - (float64(y)+d[1])*(mapTop-mapBottom)/mapRows
+				sLon := -180 + (float64(x)+d[0])*360/mapCols
+				if isLand(sLon, sLat) {
+					if land == 0 {
+						lat, lon = sLat, sLon
+					}
+					land++
+				}
+			}
+			if land < 2 {
+				mapGrid[y][x] = -2
+				continue
+			}
+			var (
+				country = -1
+				nearest = 8.0
+			)
+			for i, c := range mapCountries {
+				for _, b := range c.Boxes {
+					dx := math.Max(math.Max(b[0]-lon, lon-b[2]), 0)
+					dy := math.Max(math.Max(b[1]-lat, lat-b[3]), 0)
+					if d := math.Hypot(dx, dy); d == 0 {
+						country, nearest = i, 0
+						break
+					} else if d < nearest {
+						country, nearest = i, d
+					}
+				}
+				if nearest == 0 {
+					break
+				}
+			}
+			mapGrid[y][x] = country
+		}
+	}
+}
+
+// mapLevel returns the color level (1 to mapLevels) of count on logarithmic scale
+func mapLevel(count, max uint64) int {
+	if count == 0 || max == 0 {
+		return 0
+	}
+	level := int(math.Ceil(math.Log(float64(count)+1) / math.Log(float64(max)+1) * mapLevels))
+	if level < 1 {
+		level = 1
+	} else if level > mapLevels {
+		level = mapLevels
+	}
+	return level
+}
+
+// worldMap renders the land cells colored by the counts of their countries
+func worldMap(counts map[int]uint64, max uint64) string {
+	initMap()
+	var builder strings.Builder
+	for y, row := range mapGrid {
+		var (
+			line  strings.Builder
+			color string
+		)
+		for _, i := range row {
+			var c, s string
+			switch {
+			case i == -2:
+				s = " "
+			case i >= 0 && counts[i] > 0:
+				level := mapLevel(counts[i], max)
+				c, s = mapColors[level-1], mapChars[level-1]
+			default:
+				c, s = colorLightBlack, "·"
+			}
+			if c != color && s != " " {
+				if color != "" {
+					line.WriteString(colorReset)
+				}
+				line.WriteString(c)
+				color = c
+			}
+			line.WriteString(s)
+		}
+		if color != "" {
+			line.WriteString(colorReset)
+		}
+		builder.WriteString(strings.TrimRight(line.String(), " "))
+		if y < len(mapGrid)-1 {
+			builder.WriteString("\n")
+		}
+	}
+	return builder.String()
+}
+
+// mapLegend returns the count range of each level
+func mapLegend(max uint64) string {
+	var parts []string
+	for level := 1; level <= mapLevels; level++ {
+		var (
+			lo = uint64(math.Floor(math.Pow(float64(max)+1, float64(level-1)/mapLevels)))
+			hi = uint64(math.Floor(math.Pow(float64(max)+1, float64(level)/mapLevels))) - 1
+		)
+		if level == 1 || lo < 1 {
+			lo = 1
+		}
+		if level == mapLevels || hi > max {
+			hi = max
+		}
+		if lo > hi {
+			continue
+		}
+		r := fmt.Sprintf("%d", lo)
+		if hi > lo {
+			r += fmt.Sprintf("-%d", hi)
+		}
+		parts = append(parts, colorf(mapChars[level-1]+" "+r, mapColors[level-1]))
+	}
+	return strings.Join(parts, "   ") + "   " + colorf("· 0", colorLightBlack)
+}
+
+// mapf renders the groups of countries as choropleth world map with legend and top 10 countries,
+// the other groups are rendered as bar chart
+func mapf(title string, body map[string][][]interface{}) {
+	initMap()
+	var (
+		builder strings.Builder
+		rest    = make(map[string][][]interface{})
+		first   = true
+	)
+	for k, v := range body {
+		var (
+			counts = make(map[int]uint64)
+			max    uint64
+		)
+		for _, o := range v {
+			if i, ok := mapIndex[strings.ToLower(strings.TrimSpace(toStr(o[0])))]; ok {
+				if counts[i] += o[1].(uint64); counts[i] > max {
+					max = counts[i]
+				}
+			}
+		}
+		if len(counts) == 0 {
+			rest[k] = v
+			continue
+		}
+		if !first {
+			builder.WriteString("\n\n\n")
+		} else {
+			first = false
+		}
+		builder.WriteString(colorf("Type: "+k, colorLightGreen) + "\n\n")
+		builder.WriteString(worldMap(counts, max) + "\n\n")
+		builder.WriteString(mapLegend(max) + "\n\n")
+		if len(v) > 10 {
+			v = v[:10]
+		}
+		for j, o := range v {
+			var (
+				color = colorLightBlack
+				char  = "·"
+				name  = omitStr(o[0], 35)
+			)
+			if i, ok := mapIndex[strings.ToLower(strings.TrimSpace(toStr(o[0])))]; ok {
+				level := mapLevel(counts[i], max)
+				color, char = mapColors[level-1], mapChars[level-1]
+			} else {
+				name += " (not on map)"
+			}
+			builder.WriteString(colorf(fmt.Sprintf("%s %5.2f%%%% - %s  [%d]", char, o[2].(float64)*100, name, o[1]), color))
+			if j < len(v)-1 {
+				builder.WriteString("\n")
+			}
+		}
+	}
+	if builder.Len() > 0 {
+		infof(title, builder.String())
+	}
+	if len(rest) > 0 {
+		histf(strings.TrimSuffix(title, " - MAP")+" - HIST", rest)
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gyyyy/ZoomEye-go/zoomeye"
+)
+
+// xlsxMaxCell is the max number of characters that a cell of Excel can contain
+const xlsxMaxCell = 32767
+
+// xlsxPercent represents a ratio displayed as percentage in workbook
+type xlsxPercent float64
+
+// xlsxSheet represents a worksheet, the first row is the bold header
+type xlsxSheet struct {
+	Name string
+	Rows [][]interface{}
+}
+
+func xlsxColumn(i int) string {
+	var s string
+	for i++; i > 0; i = (i - 1) / 26 {
+		s = string(rune('A'+(i-1)%26)) + s
+	}
+	return s
+}
+
+func xlsxSheetName(name string, used map[string]bool) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if r := []rune(name); len(r) > 31 {
+		name = string(r[:31])
+	}
+	for i, base := 2, name; used[strings.ToLower(name)]; i++ {
+		suffix := fmt.Sprintf(" (%d)", i)
+		if r := []rune(base); len(r)+len(suffix) > 31 {
+			base = string(r[:31-len(suffix)])
+		}
+		name = base + suffix
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
+
+func xlsxCell(buf *bytes.Buffer, ref string, v interface{}, header bool) {
+	style := ""
+	if header {
+		style = ` s="1"`
+	}
+	switch v := v.(type) {
+	case nil:
+		return
+	case xlsxPercent:
+		fmt.Fprintf(buf, `<c r="%s" s="2"><v>%s</v></c>`, ref, strconv.FormatFloat(float64(v), 'f', -1, 64))
+	case int, int64, uint64:
+		fmt.Fprintf(buf, `<c r="%s"%s><v>%d</v></c>`, ref, style, v)
+	case float64:
+		fmt.Fprintf(buf, `<c r="%s"%s><v>%s</v></c>`, ref, style, strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		s := toStr(v)
+		if r := []rune(s); len(r) > xlsxMaxCell {
+			s = string(r[:xlsxMaxCell])
+		}
+		fmt.Fprintf(buf, `<c r="%s" t="inlineStr"%s><is><t xml:space="preserve">`, ref, style)
+		xml.EscapeText(buf, []byte(s))
+		buf.WriteString(`</t></is></c>`)
+	}
+}
+
+func xlsxWorksheet(sheet *xlsxSheet) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	if len(sheet.Rows) > 1 {
+		buf.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	}
+	buf.WriteString(`<sheetData>`)
+	for i, row := range sheet.Rows {
+		fmt.Fprintf(&buf, `<row r="%d">`, i+1)
+		for j, v := range row {
+			xlsxCell(&buf, xlsxColumn(j)+strconv.Itoa(i+1), v, i == 0)
+		}
+		buf.WriteString(`</row>`)
+	}
+	buf.WriteString(`</sheetData></worksheet>`)
+	return buf.Bytes()
+}
+
+// writeXLSX writes the sheets into an Office Open XML workbook with inline strings
+func writeXLSX(path string, sheets []*xlsxSheet) error {
+	var (
+		buf   bytes.Buffer
+		w     = zip.NewWriter(&buf)
+		used  = make(map[string]bool)
+		types strings.Builder
+		books strings.Builder
+		rels  strings.Builder
+	)
+	for i, sheet := range sheets {
+		n := i + 1
+		fmt.Fprintf(&types, `<Override PartName="/xl/worksheets/sheet%d.xml" `+
+			`ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+		fmt.Fprintf(&books, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlAttr(xlsxSheetName(sheet.Name, used)), n, n)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" `+
+			`Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, n, n)
+	}
+	fmt.Fprintf(&rels, `<Relationship Id="rId%d" `+
+		`Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(sheets)+1)
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"[Content_Types].xml", []byte(xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+			types.String() + `</Types>`)},
+		{"_rels/.rels", []byte(xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`)},
+		{"xl/workbook.xml", []byte(xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+			`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>` + books.String() + `</sheets></workbook>`)},
+		{"xl/_rels/workbook.xml.rels", []byte(xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			rels.String() + `</Relationships>`)},
+		{"xl/styles.xml", []byte(xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+			`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+			`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+			`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+			`<cellXfs count="3"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+			`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+			`<xf numFmtId="10" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs>` +
+			`</styleSheet>`)},
+	}
+	for i, sheet := range sheets {
+		files = append(files, struct {
+			name string
+			data []byte
+		}{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), xlsxWorksheet(sheet)})
+	}
+	for _, f := range files {
+		fw, err := w.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err = fw.Write(f.data); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return writeFile(path, buf.Bytes())
+}
+
+func xmlAttr(s string) string {
+	var builder strings.Builder
+	xml.EscapeText(&builder, []byte(s))
+	return builder.String()
+}
+
+// flattenMatch flattens the nested fields of match into dotted keys, lists of scalars are joined by comma
+// and the others are kept as JSON
+func flattenMatch(prefix string, o interface{}, flat map[string]interface{}) {
+	switch o := o.(type) {
+	case map[string]interface{}:
+		for k, v := range o {
+			if prefix != "" {
+				k = prefix + "." + k
+			}
+			flattenMatch(k, v, flat)
+		}
+	case []interface{}:
+		for _, v := range o {
+			switch v.(type) {
+			case map[string]interface{}, []interface{}:
+				b, _ := json.Marshal(o)
+				flat[prefix] = string(b)
+				return
+			}
+		}
+		flat[prefix] = toStr(o)
+	default:
+		flat[prefix] = o
+	}
+}
+
+func tableSheet(name string, rows []map[string]interface{}, first ...string) *xlsxSheet {
+	var (
+		seen    = make(map[string]bool)
+		columns []string
+	)
+	for _, k := range first {
+		seen[k] = true
+	}
+	for _, row := range rows {
+		for k := range row {
+			if !seen[k] {
+				seen[k] = true
+				columns = append(columns, k)
+			}
+		}
+	}
+	sort.Strings(columns)
+	for i := len(first) - 1; i >= 0; i-- {
+		columns = append([]string{first[i]}, columns...)
+	}
+	sheet := &xlsxSheet{
+		Name: name,
+		Rows: [][]interface{}{make([]interface{}, len(columns))},
+	}
+	for i, k := range columns {
+		sheet.Rows[0][i] = k
+	}
+	for _, row := range rows {
+		values := make([]interface{}, len(columns))
+		for i, k := range columns {
+			values[i] = row[k]
+		}
+		sheet.Rows = append(sheet.Rows, values)
+	}
+	return sheet
+}
+
+func countSheets(prefix string, body map[string][][]interface{}) []*xlsxSheet {
+	keys := make([]string, 0, len(body))
+	for k := range body {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	sheets := make([]*xlsxSheet, 0, len(keys))
+	for _, k := range keys {
+		sheet := &xlsxSheet{
+			Name: prefix + " " + k,
+			Rows: [][]interface{}{{"Name", "Count", "Percent"}},
+		}
+		for _, v := range body[k] {
+			sheet.Rows = append(sheet.Rows, []interface{}{toStr(v[0]), v[1], xlsxPercent(v[2].(float64))})
+		}
+		sheets = append(sheets, sheet)
+	}
+	return sheets
+}
+
+// resultWorkbook builds sheets of raw matches, filtered rows, facets, statistics and metadata
+func resultWorkbook(result *zoomeye.SearchResult, meta *reportMeta, filtered []map[string]interface{}, facets, stats []string) []*xlsxSheet {
+	rows := make([]map[string]interface{}, len(result.Matches))
+	for i, m := range result.Matches {
+		rows[i] = make(map[string]interface{})
+		flattenMatch("", map[string]interface{}(m), rows[i])
+	}
+	first := []string{"ip", "portinfo.port"}
+	if result.Type == "web" {
+		first = []string{"site", "ip"}
+	}
+	sheets := []*xlsxSheet{tableSheet("Matches", rows, first...)}
+	if len(filtered) > 0 {
+		rows = make([]map[string]interface{}, len(filtered))
+		for i, v := range filtered {
+			rows[i] = make(map[string]interface{}, len(v))
+			for k, o := range v {
+				if k != "_index" {
+					rows[i][k] = o
+				}
+			}
+		}
+		sheets = append(sheets, tableSheet("Filtered", rows, "ip"))
+	}
+	if len(facets) > 0 {
+		sheets = append(sheets, countSheets("Facet", facetBody(result, facets))...)
+	}
+	if len(stats) > 0 {
+		sheets = append(sheets, countSheets("Stat", statBody(result, stats))...)
+	}
+	if meta == nil {
+		meta = &reportMeta{}
+	}
+	sheets = append(sheets, &xlsxSheet{
+		Name: "Metadata",
+		Rows: [][]interface{}{
+			{"Key", "Value"},
+			{"Dork", meta.Dork},
+			{"Source", meta.Source},
+			{"Resource", result.Type},
+			{"Time", time.Now().Format("2006-01-02 15:04:05")},
+			{"Total", result.Total},
+			{"Fetched", len(result.Matches)},
+			{"Version", "ZoomEye-go " + ver},
+		},
+	})
+	return sheets
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"encoding/xml"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gyyyy/ZoomEye-go/zoomeye"
+)
+
+// workbookResult is the host search exported into the workbook
+const workbookResult = `{
+	"total": 3,
+	"matches": [
+		{"ip": "10.0.0.1", "portinfo": {"port": 80, "service": "http", "app": "nginx"}, "geoinfo": {"country": {"names": {"en": "China"}}}},
+		{"ip": "10.0.0.2", "portinfo": {"port": 22, "service": "ssh", "app": "OpenSSH"}, "geoinfo": {"country": {"names": {"en": "Japan"}}}},
+		{"ip": "10.0.0.3", "portinfo": {"port": 8080, "service": "http", "app": "nginx"}, "geoinfo": {"country": {"names": {"en": "China"}}}}
+	],
+	"facets": {"product": [{"name": "nginx", "count": 2}, {"name": "OpenSSH", "count": 1}]}
+}`
+
+func TestWorkbook(t *testing.T) {
+	result := &zoomeye.SearchResult{Type: "host"}
+	if err := json.Unmarshal([]byte(workbookResult), result); err != nil {
+		t.FailNow()
+	}
+	var (
+		meta   = &reportMeta{Dork: "nginx", Source: "search"}
+		sheets = resultWorkbook(result, meta, result.Filter("ip", "port"), []string{"app"}, []string{"country", "service"})
+		names  []string
+	)
+	for _, v := range sheets {
+		names = append(names, v.Name)
+	}
+	if strings.Join(names, ",") != "Matches,Filtered,Facet app,Stat country,Stat service,Metadata" {
+		t.FailNow()
+	}
+	if len(sheets[0].Rows) != 4 || sheets[0].Rows[0][0] != "ip" || sheets[0].Rows[1][0] != "10.0.0.1" {
+		t.Fail()
+	}
+	path := filepath.Join(t.TempDir(), "nginx.xlsx")
+	if err := writeXLSX(path, sheets); err != nil {
+		t.FailNow()
+	}
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.FailNow()
+	}
+	defer r.Close()
+	if len(r.File) != 5+len(sheets) {
+		t.Fail()
+	}
+	for _, f := range r.File {
+		rc, _ := f.Open()
+		if err = xml.NewDecoder(rc).Decode(new(interface{})); err != nil {
+			t.Fail()
+		}
+		rc.Close()
+	}
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 702: "AAA"} {
+		if xlsxColumn(i) != want {
+			t.Fail()
+		}
+	}
+	used := make(map[string]bool)
+	if xlsxSheetName("Stat a/b", used) != "Stat a_b" || xlsxSheetName("stat a_b", used) != "stat a_b (2)" {
+		t.Fail()
+	}
+}
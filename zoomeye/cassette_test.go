@@ -0,0 +1,63 @@
+package zoomeye
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestCassette(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassettes", "zoomeye.json")
+	c, err := LoadCassette(path, CassetteRecord)
+	if err != nil {
+		t.FailNow()
+	}
+	var (
+		zoom = NewWithKey(tAPIKey, "")
+		real = RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if req.URL.Path == loginAPI {
+				return tResponse(req, 200, `{"access_token":"secret-jwt"}`), nil
+			}
+			return tResponse(req, 200, `{"plan":"developer","resources":{"search":10000,"stats":1000,"interval":"month"}}`), nil
+		})
+	)
+	zoom.cli = &http.Client{
+		Transport: c.Middleware()(real),
+	}
+	if tok, err := zoom.Login(tUsername, tPassword); err != nil || tok != "secret-jwt" {
+		t.FailNow()
+	}
+	if _, err = zoom.ResourcesInfo(); err != nil {
+		t.FailNow()
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.FailNow()
+	}
+	for _, s := range []string{tAPIKey, `"password":"` + tPassword + `"`, "secret-jwt"} {
+		if bytes.Contains(b, []byte(s)) {
+			t.Errorf("cassette contains unredacted %q", s)
+		}
+	}
+	if c, err = LoadCassette(path, CassetteReplay); err != nil || len(c.Interactions) != 2 {
+		t.FailNow()
+	}
+	zoom = NewWithKey(tAPIKey, "", WithCassette(c))
+	if tok, err := zoom.Login(tUsername, tPassword); err != nil || tok != redacted {
+		t.Fail()
+	}
+	for i := 0; i < 2; i++ {
+		result, err := zoom.ResourcesInfo()
+		if err != nil || result.Plan != "developer" {
+			t.Fail()
+		}
+	}
+	if _, err = zoom.HistoryIP("1.2.3.4"); err == nil {
+		t.Fail()
+	}
+	if _, err = NewWithKey("00000000-0000-00000-0000-00000000000", "", WithCassette(c)).ResourcesInfo(); err == nil {
+		t.Fail()
+	}
+}
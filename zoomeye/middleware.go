@@ -0,0 +1,103 @@
+package zoomeye
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Middleware wraps the RoundTripper of client to intercept requests and responses
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripFunc is an adapter to allow the use of ordinary functions as http.RoundTripper
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req)
+func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func chain(rt http.RoundTripper, middlewares []Middleware) http.RoundTripper {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		if middlewares[i] != nil {
+			rt = middlewares[i](rt)
+		}
+	}
+	return rt
+}
+
+// OnRequest creates middleware that calls hook before each request is sent,
+// the request passed to hook is a copy so that it can be modified safely
+func OnRequest(hook func(req *http.Request) error) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			if err := hook(req); err != nil {
+				return nil, err
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// OnResponse creates middleware that calls hook after each response is received
+func OnResponse(hook func(req *http.Request, resp *http.Response) error) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				return nil, err
+			}
+			if err = hook(req, resp); err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+			return resp, nil
+		})
+	}
+}
+
+// SetHeader creates middleware that sets header on each request
+func SetHeader(key, value string) Middleware {
+	return OnRequest(func(req *http.Request) error {
+		req.Header.Set(key, value)
+		return nil
+	})
+}
+
+// RateLimit creates middleware that sends at most n requests per period,
+// the requests over limit will wait until they are allowed, it does nothing if n or period is not positive
+func RateLimit(n int, period time.Duration) Middleware {
+	if n <= 0 || period <= 0 {
+		return func(rt http.RoundTripper) http.RoundTripper {
+			return rt
+		}
+	}
+	var (
+		interval = period / time.Duration(n)
+		next     time.Time
+		mu       sync.Mutex
+	)
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			now := time.Now()
+			if next.Before(now) {
+				next = now
+			}
+			wait := next.Sub(now)
+			next = next.Add(interval)
+			mu.Unlock()
+			if wait > 0 {
+				timer := time.NewTimer(wait)
+				defer timer.Stop()
+				select {
+				case <-req.Context().Done():
+					return nil, req.Context().Err()
+				case <-timer.C:
+				}
+			}
+			return rt.RoundTrip(req)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package zoomeye
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func tResponse(req *http.Request, code int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: code,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+		Request:    req,
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var (
+		zoom  = NewWithKey(tAPIKey, "")
+		order []string
+	)
+	zoom.Use(
+		SetHeader("X-Proxy-Signature", "signed"),
+		OnRequest(func(req *http.Request) error {
+			order = append(order, "request")
+			if req.Header.Get("X-Proxy-Signature") != "signed" || req.Header.Get("API-KEY") != tAPIKey {
+				t.Fail()
+			}
+			return nil
+		}),
+		OnResponse(func(req *http.Request, resp *http.Response) error {
+			order = append(order, "response")
+			return nil
+		}),
+	)
+	zoom.Use(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			order = append(order, "transport")
+			return tResponse(req, 200, `{"plan":"developer","resources":{"search":10000,"stats":1000,"interval":"month"}}`), nil
+		})
+	})
+	result, err := zoom.ResourcesInfo()
+	if err != nil || result.Plan != "developer" || result.Resources.Search != 10000 {
+		t.FailNow()
+	}
+	if len(order) != 3 || order[0] != "request" || order[1] != "transport" || order[2] != "response" {
+		t.Fail()
+	}
+	t.Log(order)
+}
+
+func TestRateLimit(t *testing.T) {
+	rt := RateLimit(2, 100*time.Millisecond)(RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return tResponse(req, http.StatusOK, "{}"), nil
+	}))
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "https://api.zoomeye.org/resources-info", nil)
+		if _, err := rt.RoundTrip(req); err != nil {
+			t.FailNow()
+		}
+	}
+	if since := time.Since(start); since < 100*time.Millisecond {
+		t.Fail()
+	}
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	next := RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return tResponse(req, http.StatusOK, "{}"), nil
+	})
+	for _, v := range [][2]int{{0, 1}, {-1, 1}, {1, 0}} {
+		rt := RateLimit(v[0], time.Duration(v[1])*time.Second)(next)
+		req, _ := http.NewRequest(http.MethodGet, "https://api.zoomeye.org/resources-info", nil)
+		if _, err := rt.RoundTrip(req); err != nil {
+			t.FailNow()
+		}
+	}
+}
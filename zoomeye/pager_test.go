@@ -0,0 +1,109 @@
+package zoomeye
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gyyyy/ZoomEye-go/zoomeye/zoomeyetest"
+)
+
+func TestPagerOrder(t *testing.T) {
+	srv := zoomeyetest.NewServer(zoomeyetest.WithLatency(5 * time.Millisecond))
+	defer srv.Close()
+	zoom := NewWithKey(zoomeyetest.APIKey, "", WithBaseURL(srv.URL))
+	for _, workers := range []int{1, 3, 20} {
+		var (
+			mu       sync.Mutex
+			pages    []int
+			progress []int
+		)
+		results, err := zoom.MultiPageSearch("", 10, "host", "",
+			WithWorkers(workers),
+			WithProgress(func(done, total int) {
+				mu.Lock()
+				defer mu.Unlock()
+				if total != 10 {
+					t.Fail()
+				}
+				progress = append(progress, done)
+			}),
+			WithPageHandler(func(page int, result *SearchResult, err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil || result == nil {
+					t.Fail()
+				}
+				pages = append(pages, page)
+			}),
+		)
+		if err != nil || len(results) != 10 || len(pages) != 10 || len(progress) != 10 {
+			t.FailNow()
+		}
+		for i := range pages {
+			if pages[i] != i+1 || progress[i] != i+1 {
+				t.Fail()
+			}
+		}
+	}
+	if srv.Requests("/host/search") != 30 {
+		t.Fail()
+	}
+}
+
+func TestSearchPages(t *testing.T) {
+	srv := zoomeyetest.NewServer()
+	defer srv.Close()
+	zoom := NewWithKey(zoomeyetest.APIKey, "", WithBaseURL(srv.URL))
+	results, err := zoom.SearchPages("", []int{5, 3, 3, 2}, "web", "", WithWorkers(2))
+	if err != nil || len(results) != 3 || results[5].Matches[0].FindString("site") != "www.example80.com" {
+		t.Fail()
+	}
+}
+
+func TestPageHandlers(t *testing.T) {
+	srv := zoomeyetest.NewServer()
+	defer srv.Close()
+	var (
+		zoom  = NewWithKey(zoomeyetest.APIKey, "", WithBaseURL(srv.URL))
+		calls []string
+	)
+	_, err := zoom.SearchPages("", []int{1, 2}, "host", "",
+		WithPageHandler(func(page int, _ *SearchResult, _ error) {
+			calls = append(calls, "first")
+		}),
+		WithPageHandler(func(page int, _ *SearchResult, _ error) {
+			calls = append(calls, "second")
+		}),
+	)
+	if err != nil || strings.Join(calls, ",") != "first,second,first,second" {
+		t.Fail()
+	}
+}
+
+func TestPartialResult(t *testing.T) {
+	srv := zoomeyetest.NewServer(zoomeyetest.WithFaults(
+		&zoomeyetest.Fault{Path: "/host/search", Page: 3, Status: http.StatusInternalServerError},
+		&zoomeyetest.Fault{Path: "/host/search", Page: 7, Status: http.StatusTooManyRequests},
+	))
+	defer srv.Close()
+	zoom := NewWithKey(zoomeyetest.APIKey, "", WithBaseURL(srv.URL))
+	results, err := zoom.MultiPageSearch("", 10, "host", "")
+	partial, ok := err.(*PartialResultError)
+	if !ok || len(results) != 8 {
+		t.FailNow()
+	}
+	if pages := partial.FailedPages(); len(pages) != 2 || pages[0] != 3 || pages[1] != 7 {
+		t.Fail()
+	}
+	result, err := zoom.MultiToOneSearch("", 10, "host", "")
+	if _, ok = err.(*PartialResultError); !ok || len(result.Matches) != 8*20 {
+		t.Fail()
+	}
+	if _, err = zoom.SearchPages("", []int{3, 7}, "host", ""); err == nil {
+		t.Fail()
+	}
+	t.Log(partial)
+}
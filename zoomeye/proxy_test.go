@@ -0,0 +1,159 @@
+package zoomeye
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gyyyy/ZoomEye-go/zoomeye/zoomeyetest"
+)
+
+func TestProxy(t *testing.T) {
+	srv := zoomeyetest.NewServer()
+	defer srv.Close()
+	var (
+		proxied int
+		auth    = "Basic " + base64.StdEncoding.EncodeToString([]byte("user:pass"))
+	)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Proxy-Authorization") != auth {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		proxied++
+		req, _ := http.NewRequest(r.Method, r.URL.String(), r.Body)
+		req.Header = r.Header.Clone()
+		req.Header.Del("Proxy-Authorization")
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	defer proxy.Close()
+	u, err := ParseProxy("http://user:pass@" + proxy.Listener.Addr().String())
+	if err != nil {
+		t.FailNow()
+	}
+	if _, err = NewWithKey(zoomeyetest.APIKey, "", WithBaseURL(srv.URL), WithProxy(u)).ResourcesInfo(); err != nil || proxied != 1 {
+		t.Fail()
+	}
+	if u, err = ParseProxy(proxy.Listener.Addr().String()); err != nil {
+		t.FailNow()
+	}
+	if _, err = NewWithKey(zoomeyetest.APIKey, "", WithBaseURL(srv.URL), WithProxy(u)).ResourcesInfo(); err == nil {
+		t.Fail()
+	}
+	if _, err = ParseProxy("ftp://127.0.0.1:21"); err == nil {
+		t.Fail()
+	}
+}
+
+// tSOCKS5 serves SOCKS5 CONNECT (RFC 1928) with username/password authentication (RFC 1929)
+func tSOCKS5(t *testing.T, user, pass string) (string, *int) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.FailNow()
+	}
+	t.Cleanup(func() {
+		l.Close()
+	})
+	var connected int
+	handle := func(conn net.Conn) {
+		defer conn.Close()
+		buf := make([]byte, 256)
+		// greeting: VER NMETHODS METHODS, only username/password (0x02) is accepted
+		if _, err := io.ReadFull(conn, buf[:2]); err != nil || buf[0] != 5 {
+			return
+		}
+		if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil {
+			return
+		}
+		conn.Write([]byte{5, 2})
+		// authentication: VER ULEN UNAME PLEN PASSWD
+		if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+			return
+		}
+		u := make([]byte, buf[1])
+		io.ReadFull(conn, u)
+		io.ReadFull(conn, buf[:1])
+		p := make([]byte, buf[0])
+		io.ReadFull(conn, p)
+		if string(u) != user || string(p) != pass {
+			conn.Write([]byte{1, 1})
+			return
+		}
+		conn.Write([]byte{1, 0})
+		// request: VER CMD RSV ATYP DST.ADDR DST.PORT
+		if _, err := io.ReadFull(conn, buf[:4]); err != nil || buf[1] != 1 {
+			return
+		}
+		var host string
+		switch buf[3] {
+		case 1, 4:
+			ip := make(net.IP, 4*int(buf[3]))
+			io.ReadFull(conn, ip)
+			host = ip.String()
+		case 3:
+			io.ReadFull(conn, buf[:1])
+			name := make([]byte, buf[0])
+			io.ReadFull(conn, name)
+			host = string(name)
+		default:
+			return
+		}
+		if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+			return
+		}
+		target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(buf[:2])))))
+		if err != nil {
+			conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		defer target.Close()
+		connected++
+		conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+		go io.Copy(target, conn)
+		io.Copy(conn, target)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go handle(conn)
+		}
+	}()
+	return l.Addr().String(), &connected
+}
+
+func TestSOCKS5Proxy(t *testing.T) {
+	srv := zoomeyetest.NewServer()
+	defer srv.Close()
+	addr, connected := tSOCKS5(t, "user", "pass")
+	u, err := ParseProxy("socks5://user:pass@" + addr)
+	if err != nil {
+		t.FailNow()
+	}
+	if _, err = NewWithKey(zoomeyetest.APIKey, "", WithBaseURL(srv.URL), WithProxy(u)).ResourcesInfo(); err != nil || *connected != 1 {
+		t.Fail()
+	}
+	if u, err = ParseProxy("socks5://user:wrong@" + addr); err != nil {
+		t.FailNow()
+	}
+	if _, err = NewWithKey(zoomeyetest.APIKey, "", WithBaseURL(srv.URL), WithProxy(u)).ResourcesInfo(); err == nil {
+		t.Fail()
+	}
+	if _, err = ParseProxy("socks5h://" + addr); err == nil {
+		t.Fail()
+	}
+}
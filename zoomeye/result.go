@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -64,6 +65,7 @@ var (
 		"port":    "portinfo.port",
 		"service": "portinfo.service",
 		"app":     "portinfo.product",
+		"ip":      "ip",
 		"raw":     "raw_data",
 	}
 )
@@ -131,6 +133,33 @@ func (r *ErrorResult) Error() string {
 	return r.Message
 }
 
+// PartialResultError represents error of multi-page search that some pages failed,
+// it is returned together with the successful pages
+type PartialResultError struct {
+	Pages map[int]error
+}
+
+// FailedPages returns numbers of the failed pages in order
+func (e *PartialResultError) FailedPages() []int {
+	pages := make([]int, 0, len(e.Pages))
+	for k := range e.Pages {
+		pages = append(pages, k)
+	}
+	sort.Ints(pages)
+	return pages
+}
+
+func (e *PartialResultError) Error() string {
+	var (
+		pages = e.FailedPages()
+		s     = make([]string, len(pages))
+	)
+	for i, v := range pages {
+		s[i] = fmt.Sprintf("page %d: %v", v, e.Pages[v])
+	}
+	return fmt.Sprintf("failed to search %d pages (%s)", len(pages), strings.Join(s, "; "))
+}
+
 // Result represents each type of result
 type Result interface {
 	setRawData([]byte)
@@ -0,0 +1,188 @@
+package zoomeye
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var timeLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04:05.999999",
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// ParseTime parses timestamp of ZoomEye results in any of the layouts used by API
+func ParseTime(s string) (time.Time, bool) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// TimelineEvent represents a change of port/service found in history
+type TimelineEvent struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Product string    `json:"product,omitempty"`
+	Version string    `json:"version,omitempty"`
+	Detail  string    `json:"detail,omitempty"`
+}
+
+// TimelineSpan represents a period that port/service is continuously seen
+type TimelineSpan struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// ServiceTimeline represents timeline of a port/service in history
+type ServiceTimeline struct {
+	Port      string           `json:"port"`
+	Service   string           `json:"service"`
+	FirstSeen time.Time        `json:"first_seen"`
+	LastSeen  time.Time        `json:"last_seen"`
+	Probes    int              `json:"probes"`
+	Open      bool             `json:"open"`
+	Spans     []*TimelineSpan  `json:"spans"`
+	Events    []*TimelineEvent `json:"events"`
+}
+
+// Name returns port/service of the timeline
+func (t *ServiceTimeline) Name() string {
+	if t.Service == "" {
+		return t.Port
+	}
+	return t.Port + "/" + t.Service
+}
+
+type probe struct {
+	time    time.Time
+	product string
+	version string
+}
+
+func (p *probe) String() string {
+	s := strings.TrimSpace(p.product + " " + p.version)
+	if s == "" {
+		return "[unknown]"
+	}
+	return s
+}
+
+// Timeline analyzes history of each port/service in time order, the port/service is considered closed
+// if it is not seen for longer than gap while the host is probed on other ports
+func (r *HistoryResult) Timeline(gap time.Duration) []*ServiceTimeline {
+	var (
+		groups = make(map[string][]*probe)
+		names  = make(map[string][2]string)
+		times  []time.Time
+	)
+	for _, v := range r.Data {
+		t, ok := ParseTime(v.FindString("timestamp"))
+		if !ok {
+			continue
+		}
+		var (
+			port    = v.FindString("portinfo.port")
+			service = v.FindString("portinfo.service")
+			key     = port + "/" + service
+		)
+		groups[key] = append(groups[key], &probe{
+			time:    t,
+			product: v.FindString("portinfo.product"),
+			version: v.FindString("portinfo.version"),
+		})
+		names[key] = [2]string{port, service}
+		times = append(times, t)
+	}
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].Before(times[j])
+	})
+	probedBetween := func(start, end time.Time) bool {
+		i := sort.Search(len(times), func(i int) bool {
+			return times[i].After(start)
+		})
+		return i < len(times) && times[i].Before(end)
+	}
+	timelines := make([]*ServiceTimeline, 0, len(groups))
+	for key, probes := range groups {
+		sort.SliceStable(probes, func(i, j int) bool {
+			return probes[i].time.Before(probes[j].time)
+		})
+		var (
+			first = probes[0]
+			last  = probes[len(probes)-1]
+			tl    = &ServiceTimeline{
+				Port:      names[key][0],
+				Service:   names[key][1],
+				FirstSeen: first.time,
+				LastSeen:  last.time,
+				Probes:    len(probes),
+				Open:      true,
+			}
+			span = &TimelineSpan{
+				Start: first.time,
+			}
+			open = func(p *probe) {
+				tl.Events = append(tl.Events, &TimelineEvent{
+					Time:    p.time,
+					Type:    "open",
+					Product: p.product,
+					Version: p.version,
+					Detail:  p.String(),
+				})
+			}
+		)
+		open(first)
+		for i := 1; i < len(probes); i++ {
+			var (
+				prev = probes[i-1]
+				curr = probes[i]
+			)
+			if curr.time.Sub(prev.time) > gap && probedBetween(prev.time, curr.time) {
+				span.End = prev.time
+				tl.Spans = append(tl.Spans, span)
+				tl.Events = append(tl.Events, &TimelineEvent{
+					Time:   prev.time,
+					Type:   "close",
+					Detail: fmt.Sprintf("not seen until %s", curr.time.Format("2006-01-02")),
+				})
+				span = &TimelineSpan{
+					Start: curr.time,
+				}
+				open(curr)
+			} else if curr.product != prev.product || curr.version != prev.version {
+				tl.Events = append(tl.Events, &TimelineEvent{
+					Time:    curr.time,
+					Type:    "change",
+					Product: curr.product,
+					Version: curr.version,
+					Detail:  prev.String() + " -> " + curr.String(),
+				})
+			}
+		}
+		span.End = last.time
+		tl.Spans = append(tl.Spans, span)
+		if latest := times[len(times)-1]; latest.Sub(last.time) > gap {
+			tl.Open = false
+			tl.Events = append(tl.Events, &TimelineEvent{
+				Time:   last.time,
+				Type:   "close",
+				Detail: fmt.Sprintf("not seen until %s", latest.Format("2006-01-02")),
+			})
+		}
+		timelines = append(timelines, tl)
+	}
+	sort.Slice(timelines, func(i, j int) bool {
+		if a, b := timelines[i].FirstSeen, timelines[j].FirstSeen; !a.Equal(b) {
+			return a.Before(b)
+		}
+		return timelines[i].Name() < timelines[j].Name()
+	})
+	return timelines
+}
@@ -0,0 +1,55 @@
+package zoomeye
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gyyyy/ZoomEye-go/zoomeye/zoomeyetest"
+)
+
+func TestTimeline(t *testing.T) {
+	records := zoomeyetest.History("1.2.3.4", 30)
+	records = append(records, map[string]interface{}{
+		"ip": "1.2.3.4",
+		"portinfo": map[string]interface{}{
+			"port":    21,
+			"service": "ftp",
+			"product": "vsftpd",
+			"version": "3.0.2",
+		},
+		"timestamp": "2021-01-01T00:00:00",
+	})
+	b, _ := json.Marshal(map[string]interface{}{"count": len(records), "data": records})
+	result := &HistoryResult{}
+	if err := json.Unmarshal(b, result); err != nil {
+		t.FailNow()
+	}
+	timelines := result.Timeline(7 * 24 * time.Hour)
+	if len(timelines) != 4 || timelines[0].Name() != "21/ftp" {
+		t.FailNow()
+	}
+	if ftp := timelines[0]; ftp.Open || ftp.Events[len(ftp.Events)-1].Type != "close" {
+		t.Fail()
+	}
+	for _, tl := range timelines[1:] {
+		if !tl.Open || len(tl.Spans) != 1 || tl.Probes != 10 {
+			t.Fail()
+		}
+		if tl.Port != "80" {
+			continue
+		}
+		var changes int
+		for _, e := range tl.Events {
+			if e.Type == "change" {
+				changes++
+			}
+		}
+		if changes != 3 || !tl.LastSeen.Equal(time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Fail()
+		}
+	}
+	if timelines = result.Timeline(24 * time.Hour); len(timelines[1].Spans) != 10 {
+		t.Fail()
+	}
+}
@@ -0,0 +1,123 @@
+package zoomeye
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+)
+
+// WithTLSConfig uses the specified TLS configuration for connections to ZoomEye API
+func WithTLSConfig(config *tls.Config) Option {
+	return func(z *ZoomEye) {
+		z.tlsConfig = config.Clone()
+	}
+}
+
+// WithRootCAs verifies certificates of ZoomEye API by the specified CA pool instead of system pool
+func WithRootCAs(pool *x509.CertPool) Option {
+	return func(z *ZoomEye) {
+		z.tls().RootCAs = pool
+	}
+}
+
+// WithPinnedKeys pins the SPKI SHA-256 hashes (base64 encoded, "sha256/" prefix is optional) of ZoomEye API host,
+// the connection will be rejected if none of certificates in the chain matches,
+// or if the pins are invalid by ParsePinnedKeys
+func WithPinnedKeys(pins ...string) Option {
+	return func(z *ZoomEye) {
+		z.pins = append(z.pins, pins...)
+	}
+}
+
+// ParsePinnedKeys decodes the SPKI SHA-256 hashes (base64 encoded, "sha256/" prefix is optional),
+// blank ones are ignored, but a malformed one or none left is an error
+func ParsePinnedKeys(pins ...string) ([][]byte, error) {
+	keys := make([][]byte, 0, len(pins))
+	for _, v := range pins {
+		if v = strings.TrimPrefix(strings.TrimSpace(v), "sha256/"); v == "" {
+			continue
+		}
+		b, err := base64.StdEncoding.DecodeString(v)
+		if err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("invalid pinned key: %s", v)
+		}
+		keys = append(keys, b)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no any pinned keys")
+	}
+	return keys, nil
+}
+
+// WithInsecure skips verification of certificates, API-Key and JWT may be leaked to a man-in-the-middle
+func WithInsecure() Option {
+	return func(z *ZoomEye) {
+		z.tls().InsecureSkipVerify = true
+	}
+}
+
+// LoadCABundle creates CA pool with system CAs and certificates in the PEM file
+func LoadCABundle(path string) (*x509.CertPool, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no any certificates in %s", path)
+	}
+	return pool, nil
+}
+
+func (z *ZoomEye) tls() *tls.Config {
+	if z.tlsConfig == nil {
+		z.tlsConfig = &tls.Config{}
+	}
+	return z.tlsConfig
+}
+
+func (z *ZoomEye) pinnedTLSConfig() *tls.Config {
+	config := z.tls().Clone()
+	if len(z.pins) == 0 {
+		return config
+	}
+	var host string
+	if u, err := url.Parse(z.api("")); err == nil {
+		host = u.Hostname()
+	}
+	// invalid pins reject all connections, rather than turning pinning off
+	pins, pinErr := ParsePinnedKeys(z.pins...)
+	verify := config.VerifyConnection
+	config.VerifyConnection = func(cs tls.ConnectionState) error {
+		if verify != nil {
+			if err := verify(cs); err != nil {
+				return err
+			}
+		}
+		if cs.ServerName != "" && cs.ServerName != host {
+			return nil
+		}
+		if pinErr != nil {
+			return pinErr
+		}
+		for _, cert := range cs.PeerCertificates {
+			sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			for _, pin := range pins {
+				if bytes.Equal(sum[:], pin) {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("certificate of %s does not match any pinned keys", host)
+	}
+	return config
+}
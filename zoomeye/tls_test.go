@@ -0,0 +1,51 @@
+package zoomeye
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"testing"
+
+	"github.com/gyyyy/ZoomEye-go/zoomeye/zoomeyetest"
+)
+
+func TestTLS(t *testing.T) {
+	srv := zoomeyetest.NewTLSServer()
+	defer srv.Close()
+	var (
+		cert = srv.Certificate()
+		pool = x509.NewCertPool()
+		sum  = sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		pin  = base64.StdEncoding.EncodeToString(sum[:])
+	)
+	pool.AddCert(cert)
+	if _, err := NewWithKey(zoomeyetest.APIKey, "", WithBaseURL(srv.URL)).ResourcesInfo(); err == nil {
+		t.Fail()
+	}
+	if _, err := NewWithKey(zoomeyetest.APIKey, "", WithBaseURL(srv.URL), WithInsecure()).ResourcesInfo(); err != nil {
+		t.Fail()
+	}
+	if _, err := NewWithKey(zoomeyetest.APIKey, "", WithBaseURL(srv.URL), WithRootCAs(pool)).ResourcesInfo(); err != nil {
+		t.Fail()
+	}
+	if _, err := NewWithKey(zoomeyetest.APIKey, "", WithBaseURL(srv.URL), WithRootCAs(pool),
+		WithPinnedKeys("sha256/"+pin)).ResourcesInfo(); err != nil {
+		t.Fail()
+	}
+	if _, err := NewWithKey(zoomeyetest.APIKey, "", WithBaseURL(srv.URL), WithRootCAs(pool),
+		WithPinnedKeys(base64.StdEncoding.EncodeToString(make([]byte, 32)))).ResourcesInfo(); err == nil {
+		t.Fail()
+	}
+	for _, pins := range [][]string{{"sha256/" + pin + "!"}, {pin[:20]}, {" ", ""}} {
+		if _, err := ParsePinnedKeys(pins...); err == nil {
+			t.Fail()
+		}
+		if _, err := NewWithKey(zoomeyetest.APIKey, "", WithBaseURL(srv.URL), WithRootCAs(pool),
+			WithPinnedKeys(pins...)).ResourcesInfo(); err == nil {
+			t.Fail()
+		}
+	}
+	if keys, err := ParsePinnedKeys("", "sha256/"+pin); err != nil || len(keys) != 1 {
+		t.Fail()
+	}
+}
@@ -0,0 +1,178 @@
+package zoomeye
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var traceID uint64
+
+type traceKey struct{}
+
+type traceInfo struct {
+	id       uint64
+	attempts int32
+}
+
+func withTrace(req *http.Request) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), traceKey{}, &traceInfo{
+		id: atomic.AddUint64(&traceID, 1),
+	}))
+}
+
+// TraceEvent represents trace of a request sent to ZoomEye API, API-Key, JWT and password or access_token in body have been redacted
+type TraceEvent struct {
+	ID       uint64
+	Attempt  int
+	Time     time.Time
+	Method   string
+	URL      string
+	Params   url.Values
+	Header   http.Header
+	Status   int
+	Latency  time.Duration
+	BodySize int
+	Body     []byte
+	Err      error
+}
+
+// Fields returns the event as key/value pairs for structured logging
+func (e *TraceEvent) Fields() []interface{} {
+	fields := []interface{}{
+		"id", e.ID,
+		"attempt", e.Attempt,
+		"method", e.Method,
+		"url", e.URL,
+	}
+	if len(e.Params) > 0 {
+		params := e.Params.Encode()
+		if s, err := url.QueryUnescape(params); err == nil {
+			params = s
+		}
+		fields = append(fields, "params", params)
+	}
+	fields = append(fields,
+		"status", e.Status,
+		"latency", e.Latency.Round(time.Millisecond),
+		"size", e.BodySize,
+	)
+	if e.Err != nil {
+		fields = append(fields, "error", e.Err.Error())
+	}
+	return fields
+}
+
+// Tracer receives events of all requests sent by client
+type Tracer func(e *TraceEvent)
+
+// WithTracer traces all requests of the client, it overrides the tracer enabled by ZOOMEYE_DEBUG
+func WithTracer(tracer Tracer) Option {
+	return func(z *ZoomEye) {
+		z.tracer = tracer
+	}
+}
+
+func (t Tracer) middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			e := &TraceEvent{
+				Attempt: 1,
+				Time:    time.Now(),
+				Method:  req.Method,
+				Params:  req.URL.Query(),
+				Header:  req.Header.Clone(),
+			}
+			if info, ok := req.Context().Value(traceKey{}).(*traceInfo); ok {
+				e.ID = info.id
+				e.Attempt = int(atomic.AddInt32(&info.attempts, 1))
+			}
+			u := *req.URL
+			u.RawQuery = ""
+			e.URL = u.String()
+			for _, k := range redactedHeaders {
+				if e.Header.Get(k) != "" {
+					e.Header.Set(k, redacted)
+				}
+			}
+			resp, err := next.RoundTrip(req)
+			if e.Latency = time.Since(e.Time); err != nil {
+				e.Err = err
+				t(e)
+				return nil, err
+			}
+			e.Status = resp.StatusCode
+			b, err := ioutil.ReadAll(resp.Body)
+			if resp.Body.Close(); err != nil {
+				e.Err = err
+				t(e)
+				return nil, err
+			}
+			resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+			e.Body = []byte(redactBody(b))
+			e.BodySize = len(b)
+			t(e)
+			return resp, nil
+		})
+	}
+}
+
+func formatValue(v interface{}) string {
+	s := fmt.Sprintf("%v", v)
+	if s == "" || strings.ContainsAny(s, " \t\r\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// LogTracer creates tracer that writes events to w in logfmt format,
+// and dumps response bodies into dumpDir if it is not empty
+func LogTracer(w io.Writer, dumpDir string) Tracer {
+	var mu sync.Mutex
+	return func(e *TraceEvent) {
+		var (
+			fields  = e.Fields()
+			builder strings.Builder
+		)
+		builder.WriteString("time=" + e.Time.Format(time.RFC3339) + " level=debug msg=\"zoomeye request\"")
+		for i := 0; i+1 < len(fields); i += 2 {
+			builder.WriteString(fmt.Sprintf(" %s=%s", fields[i], formatValue(fields[i+1])))
+		}
+		if dumpDir != "" && e.Body != nil {
+			var endpoint string
+			if u, err := url.Parse(e.URL); err == nil {
+				endpoint = strings.ReplaceAll(strings.Trim(u.Path, "/"), "/", "_")
+			}
+			name := fmt.Sprintf("%d_%d_%s.json", e.ID, e.Attempt, endpoint)
+			if err := os.MkdirAll(dumpDir, os.ModePerm); err == nil {
+				path := filepath.Join(dumpDir, name)
+				if err = ioutil.WriteFile(path, e.Body, 0o600); err == nil {
+					builder.WriteString(" dump=" + formatValue(path))
+				}
+			}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintln(w, builder.String())
+	}
+}
+
+// tracerFromEnv turns on tracing by ZOOMEYE_DEBUG, ZOOMEYE_DEBUG_DIR only decides where the responses are dumped
+func tracerFromEnv() Tracer {
+	s := os.Getenv("ZOOMEYE_DEBUG")
+	if on, err := strconv.ParseBool(s); s == "" || (err == nil && !on) {
+		return nil
+	}
+	return LogTracer(os.Stderr, os.Getenv("ZOOMEYE_DEBUG_DIR"))
+}
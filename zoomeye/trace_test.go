@@ -0,0 +1,82 @@
+package zoomeye
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gyyyy/ZoomEye-go/zoomeye/zoomeyetest"
+)
+
+func TestTracer(t *testing.T) {
+	srv := zoomeyetest.NewServer(zoomeyetest.WithFaults(&zoomeyetest.Fault{Status: http.StatusBadGateway, Times: 1}))
+	defer srv.Close()
+	var (
+		events []*TraceEvent
+		buf    bytes.Buffer
+		dir    = t.TempDir()
+		logger = LogTracer(&buf, dir)
+		retry  = func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+				resp, err := next.RoundTrip(req)
+				if err == nil && resp.StatusCode == http.StatusBadGateway {
+					resp.Body.Close()
+					return next.RoundTrip(req)
+				}
+				return resp, err
+			})
+		}
+	)
+	zoom := NewWithKey(zoomeyetest.APIKey, "", WithBaseURL(srv.URL), WithMiddleware(retry), WithTracer(func(e *TraceEvent) {
+		events = append(events, e)
+		logger(e)
+	}))
+	if _, err := zoom.DorkSearch("nginx", 1, "host", ""); err != nil {
+		t.FailNow()
+	}
+	if len(events) != 2 || events[1].Attempt != 2 || events[0].ID != events[1].ID || events[1].Status != 200 {
+		t.FailNow()
+	}
+	if events[1].Params.Get("query") != "nginx" || events[1].Header.Get("API-KEY") != redacted {
+		t.Fail()
+	}
+	if s := buf.String(); strings.Contains(s, zoomeyetest.APIKey) || strings.Count(s, "\n") != 2 || !strings.Contains(s, "status=502") {
+		t.Fail()
+	}
+	if files, err := ioutil.ReadDir(dir); err != nil || len(files) != 2 {
+		t.Fail()
+	}
+	t.Log(buf.String())
+}
+
+func TestTracerRedactBody(t *testing.T) {
+	srv := zoomeyetest.NewServer()
+	defer srv.Close()
+	var (
+		events []*TraceEvent
+		buf    bytes.Buffer
+		dir    = t.TempDir()
+		logger = LogTracer(&buf, dir)
+	)
+	zoom := New(WithBaseURL(srv.URL), WithTracer(func(e *TraceEvent) {
+		events = append(events, e)
+		logger(e)
+	}))
+	token, err := zoom.Login(zoomeyetest.Username, zoomeyetest.Password)
+	if err != nil || token == "" || len(events) != 1 {
+		t.FailNow()
+	}
+	if b := string(events[0].Body); strings.Contains(b, token) || !strings.Contains(b, redacted) {
+		t.Fail()
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil || len(files) != 1 {
+		t.FailNow()
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dir, files[0].Name())); err != nil || strings.Contains(string(b), token) {
+		t.Fail()
+	}
+}
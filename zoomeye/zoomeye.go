@@ -2,36 +2,37 @@ package zoomeye
 
 import (
 	"bytes"
-	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
-	"sync"
-	"sync/atomic"
 	"time"
 )
 
 const (
-	loginAPI    = "https://api.zoomeye.org/user/login"
-	userinfoAPI = "https://api.zoomeye.org/resources-info"
-	searchAPI   = "https://api.zoomeye.org/%s/search"
-	historyAPI  = "https://api.zoomeye.org/both/search?history=true&ip=%s"
+	baseAPI     = "https://api.zoomeye.org"
+	loginAPI    = "/user/login"
+	userinfoAPI = "/resources-info"
+	searchAPI   = "/%s/search"
+	historyAPI  = "/both/search?history=true&ip=%s"
 )
 
 var httpCli = &http.Client{
-	Timeout: 30 * time.Second,
-	Transport: &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-		},
-	},
+	Timeout:   30 * time.Second,
+	Transport: newTransport(nil, nil),
 }
 
+const defaultWorkers = 5
+
+// ErrNoResults is returned by search if the dork has no any results
+var ErrNoResults = errors.New("no any results for the dork")
+
 var defaultFacets = map[string]string{
 	"host": "app,device,service,os,port,country,city",
 	"web":  "webapp,component,framework,frontend,server,waf,os,country,city",
@@ -41,17 +42,117 @@ var defaultFacets = map[string]string{
 type ZoomEye struct {
 	apiKey      string
 	accessToken string
+	baseURL     string
+	tlsConfig   *tls.Config
+	pins        []string
+	proxy       *url.URL
+	middlewares []Middleware
+	cassette    *Cassette
+	tracer      Tracer
+	cli         *http.Client
+}
+
+// Option represents option of ZoomEye client
+type Option func(*ZoomEye)
+
+// WithBaseURL sends all requests of the client to the specified API server instead of ZoomEye
+func WithBaseURL(u string) Option {
+	return func(z *ZoomEye) {
+		z.baseURL = strings.TrimRight(u, "/")
+	}
+}
+
+// WithMiddleware appends middlewares to the client
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(z *ZoomEye) {
+		z.middlewares = append(z.middlewares, middlewares...)
+	}
+}
+
+// WithCassette records or replays all requests of the client by cassette,
+// it overrides the cassette specified by ZOOMEYE_CASSETTE_MODE and ZOOMEYE_CASSETTE
+func WithCassette(c *Cassette) Option {
+	return func(z *ZoomEye) {
+		z.cassette = c
+	}
+}
+
+// Use appends middlewares to the client, the first one is the outermost,
+// it should be called before sending any requests
+func (z *ZoomEye) Use(middlewares ...Middleware) {
+	z.middlewares = append(z.middlewares, middlewares...)
+	z.setup()
+}
+
+// With returns a copy of the client with middlewares appended, the client itself is not changed
+func (z *ZoomEye) With(middlewares ...Middleware) *ZoomEye {
+	c := *z
+	c.middlewares = append(append([]Middleware(nil), z.middlewares...), middlewares...)
+	c.setup()
+	return &c
+}
+
+func newTransport(config *tls.Config, proxy *url.URL) *http.Transport {
+	t := &http.Transport{
+		TLSClientConfig:     config,
+		TLSHandshakeTimeout: 10 * time.Second,
+		IdleConnTimeout:     90 * time.Second,
+		MaxIdleConnsPerHost: 20,
+	}
+	if proxy != nil {
+		t.Proxy = http.ProxyURL(proxy)
+	}
+	return t
+}
+
+func (z *ZoomEye) setup() {
+	custom := z.tlsConfig != nil || len(z.pins) > 0 || z.proxy != nil
+	if !custom && len(z.middlewares) == 0 && z.cassette == nil && z.tracer == nil {
+		z.cli = nil
+		return
+	}
+	rt := httpCli.Transport
+	if custom {
+		rt = newTransport(z.pinnedTLSConfig(), z.proxy)
+	}
+	if z.cassette != nil {
+		rt = z.cassette.Middleware()(rt)
+	}
+	if z.tracer != nil {
+		rt = z.tracer.middleware()(rt)
+	}
+	z.cli = &http.Client{
+		Timeout:   httpCli.Timeout,
+		Transport: chain(rt, z.middlewares),
+	}
+}
+
+func (z *ZoomEye) client() *http.Client {
+	if z.cli == nil {
+		return httpCli
+	}
+	return z.cli
+}
+
+func (z *ZoomEye) api(path string) string {
+	if z.baseURL == "" {
+		return baseAPI + path
+	}
+	return z.baseURL + path
 }
 
 func (z *ZoomEye) request(method, u string, body io.Reader, result Result) error {
-	req, err := http.NewRequest(method, u, body)
+	req, err := http.NewRequest(method, z.api(u), body)
+	if err != nil {
+		return err
+	}
 	if z.apiKey != "" {
 		req.Header.Set("API-KEY", z.apiKey)
 	}
 	if z.accessToken != "" {
 		req.Header.Set("Authorization", "JWT "+z.accessToken)
 	}
-	resp, err := httpCli.Do(req)
+	resp, err := z.client().Do(withTrace(req))
 	if err != nil {
 		return err
 	}
@@ -158,89 +259,182 @@ func (z *ZoomEye) DorkSearch(dork string, page int, resource string, facet strin
 		return nil, err
 	}
 	if len(result.Matches) == 0 {
-		return nil, fmt.Errorf("no any results for the dork")
+		return nil, ErrNoResults
 	}
 	return result, nil
 }
 
-func (z *ZoomEye) conMPSearch(dork string, maxPage int, resource string, facet string) (map[int]*SearchResult, error) {
-	var (
-		results     = make(map[int]*SearchResult)
-		ch          = make(chan map[string]interface{}, maxPage-1)
-		ctx, cancel = context.WithCancel(context.Background())
-	)
-	defer close(ch)
-	defer cancel()
+// SearchOption represents option of multi-page search
+type SearchOption func(*searchOptions)
+
+type searchOptions struct {
+	workers  int
+	progress func(done, total int)
+	handler  func(page int, result *SearchResult, err error)
+}
+
+func newSearchOptions(opts []SearchOption) *searchOptions {
+	o := &searchOptions{
+		workers: defaultWorkers,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.workers <= 0 {
+		o.workers = 1
+	}
+	return o
+}
+
+// WithWorkers sets number of pages searched concurrently, default is 5
+func WithWorkers(n int) SearchOption {
+	return func(o *searchOptions) {
+		o.workers = n
+	}
+}
+
+// WithProgress calls fn after each page is searched, whether it succeeds or not
+func WithProgress(fn func(done, total int)) SearchOption {
+	return func(o *searchOptions) {
+		o.progress = fn
+	}
+}
+
+// WithPageHandler calls fn exactly once for every page in page order,
+// the handlers of multiple options are all called in the order they are passed
+func WithPageHandler(fn func(page int, result *SearchResult, err error)) SearchOption {
+	return func(o *searchOptions) {
+		prev := o.handler
+		if prev == nil {
+			o.handler = fn
+			return
+		}
+		o.handler = func(page int, result *SearchResult, err error) {
+			prev(page, result, err)
+			fn(page, result, err)
+		}
+	}
+}
+
+type pageResult struct {
+	index  int
+	result *SearchResult
+	err    error
+}
+
+func (z *ZoomEye) conMPSearch(dork string, pages []int, resource string, facet string, opts *searchOptions) (map[int]*SearchResult, map[int]error) {
 	var (
-		wg        sync.WaitGroup
-		groupSize = 20
+		n       = len(pages)
+		results = make(map[int]*SearchResult, n)
+		errs    = make(map[int]error)
+		jobs    = make(chan int, n)
+		ch      = make(chan *pageResult, n)
+		workers = opts.workers
 	)
-	if maxPage < 21 {
-		groupSize = maxPage - 1
+	if workers > n {
+		workers = n
+	}
+	for i := range pages {
+		jobs <- i
 	}
-	var currPage int32 = 1
-	for i := 0; i < groupSize; i++ {
-		wg.Add(1)
+	close(jobs)
+	for i := 0; i < workers; i++ {
 		go func() {
-			defer wg.Done()
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				default:
-					page := int(atomic.AddInt32(&currPage, 1))
-					if page > maxPage {
-						return
-					}
-					m := map[string]interface{}{
-						"page": page,
-					}
-					if res, err := z.DorkSearch(dork, page, resource, facet); err != nil {
-						m["result"] = err
-					} else {
-						m["result"] = res
-					}
-					ch <- m
+			for i := range jobs {
+				res, err := z.DorkSearch(dork, pages[i], resource, facet)
+				ch <- &pageResult{
+					index:  i,
+					result: res,
+					err:    err,
 				}
 			}
 		}()
 	}
 	var (
-		err     error
-		waiting bool
-		done    = make(chan struct{}, 1)
+		pending = make(map[int]*pageResult)
+		next    int
 	)
-RCPT_LOOP:
-	for i := 0; i < groupSize; i++ {
-		select {
-		case <-ctx.Done():
-			if !waiting {
-				waiting = true
-				go func() {
-					wg.Wait()
-					close(done)
-				}()
+	for done := 1; done <= n; done++ {
+		c := <-ch
+		pending[c.index] = c
+		if opts.progress != nil {
+			opts.progress(done, n)
+		}
+		for ; next < n; next++ {
+			c, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			page := pages[next]
+			if c.err != nil {
+				errs[page] = c.err
+			} else {
+				results[page] = c.result
 			}
-		case <-done:
-			break RCPT_LOOP
-		case c := <-ch:
-			switch res := c["result"].(type) {
-			case error:
-				cancel()
-				err = res
-			case *SearchResult:
-				results[c["page"].(int)] = res
+			if opts.handler != nil {
+				opts.handler(page, c.result, c.err)
 			}
 		}
 	}
-	if wg.Wait(); err != nil && len(results) == 0 {
+	return results, errs
+}
+
+func pagesError(results map[int]*SearchResult, errs map[int]error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	if len(results) == 0 {
+		return firstError(errs)
+	}
+	return &PartialResultError{
+		Pages: errs,
+	}
+}
+
+func firstError(errs map[int]error) error {
+	var (
+		first = -1
+		err   error
+	)
+	for page, e := range errs {
+		if first < 0 || page < first {
+			first, err = page, e
+		}
+	}
+	return err
+}
+
+// SearchPages searches the specified pages of data according to dork concurrently,
+// *PartialResultError is returned together with the successful pages if some pages failed
+func (z *ZoomEye) SearchPages(dork string, pages []int, resource string, facet string, opts ...SearchOption) (map[int]*SearchResult, error) {
+	sorted := make([]int, 0, len(pages))
+	for _, page := range pages {
+		if page > 0 {
+			sorted = append(sorted, page)
+		}
+	}
+	sort.Ints(sorted)
+	for i := 1; i < len(sorted); {
+		if sorted[i] == sorted[i-1] {
+			sorted = append(sorted[:i], sorted[i+1:]...)
+		} else {
+			i++
+		}
+	}
+	var (
+		results, errs = z.conMPSearch(dork, sorted, resource, facet, newSearchOptions(opts))
+		err           = pagesError(results, errs)
+	)
+	if len(results) == 0 {
 		return nil, err
 	}
-	return results, nil
+	return results, err
 }
 
-// MultiPageSearch searches multiple pages of data according to dork
-func (z *ZoomEye) MultiPageSearch(dork string, maxPage int, resource string, facet string) (map[int]*SearchResult, error) {
+// MultiPageSearch searches multiple pages of data according to dork,
+// *PartialResultError is returned together with the successful pages if some pages failed
+func (z *ZoomEye) MultiPageSearch(dork string, maxPage int, resource string, facet string, opts ...SearchOption) (map[int]*SearchResult, error) {
 	if maxPage <= 0 {
 		maxPage = 1
 	}
@@ -252,9 +446,15 @@ func (z *ZoomEye) MultiPageSearch(dork string, maxPage int, resource string, fac
 	if info.Resources.Search%20 > 0 {
 		allowPage++
 	}
-	results := make(map[int]*SearchResult)
+	var (
+		o       = newSearchOptions(opts)
+		results = make(map[int]*SearchResult)
+	)
 	if allowPage > 0 {
 		res, err := z.DorkSearch(dork, 1, resource, facet)
+		if o.handler != nil {
+			o.handler(1, res, err)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -270,41 +470,34 @@ func (z *ZoomEye) MultiPageSearch(dork string, maxPage int, resource string, fac
 	if maxPage > allowPage {
 		maxPage = allowPage
 	}
-	if maxPage > 5 {
-		corResults, err := z.conMPSearch(dork, maxPage, resource, facet)
-		if err != nil {
-			if len(results) > 0 {
-				return results, nil
-			}
-			return nil, err
-		}
-		for k, v := range results {
-			if _, ok := corResults[k]; !ok {
-				corResults[k] = v
-			}
+	if maxPage <= 1 {
+		if o.progress != nil && len(results) > 0 {
+			o.progress(1, 1)
 		}
-		return corResults, nil
-	}
-	for i := 1; i < maxPage; i++ {
-		var (
-			page = i + 1
-			res  *SearchResult
-		)
-		if res, err = z.DorkSearch(dork, page, resource, facet); err != nil {
-			break
+		return results, nil
+	}
+	if progress := o.progress; progress != nil {
+		progress(1, maxPage)
+		o.progress = func(done, total int) {
+			progress(done+1, total+1)
 		}
-		results[page] = res
 	}
-	if err != nil && len(results) == 0 {
-		return nil, err
+	pages := make([]int, 0, maxPage-1)
+	for i := 2; i <= maxPage; i++ {
+		pages = append(pages, i)
+	}
+	corResults, errs := z.conMPSearch(dork, pages, resource, facet, o)
+	for k, v := range corResults {
+		results[k] = v
 	}
-	return results, nil
+	return results, pagesError(results, errs)
 }
 
-// MultiToOneSearch searches multiple pages of data according to dork, and merges all results
-func (z *ZoomEye) MultiToOneSearch(dork string, maxPage int, resource string, facet string) (*SearchResult, error) {
-	results, err := z.MultiPageSearch(dork, maxPage, resource, facet)
-	if err != nil {
+// MultiToOneSearch searches multiple pages of data according to dork, and merges all results,
+// *PartialResultError is returned together with the merged result if some pages failed
+func (z *ZoomEye) MultiToOneSearch(dork string, maxPage int, resource string, facet string, opts ...SearchOption) (*SearchResult, error) {
+	results, err := z.MultiPageSearch(dork, maxPage, resource, facet, opts...)
+	if results == nil {
 		return nil, err
 	}
 	result := &SearchResult{
@@ -316,7 +509,7 @@ func (z *ZoomEye) MultiToOneSearch(dork string, maxPage int, resource string, fa
 			n--
 		}
 	}
-	return result, nil
+	return result, err
 }
 
 // HistoryIP queries IP history information
@@ -332,14 +525,21 @@ func (z *ZoomEye) HistoryIP(ip string) (*HistoryResult, error) {
 }
 
 // NewWithKey creates instance of ZoomEye with API-Key and AccessToken
-func NewWithKey(apiKey, accessToken string) *ZoomEye {
-	return &ZoomEye{
+func NewWithKey(apiKey, accessToken string, opts ...Option) *ZoomEye {
+	z := &ZoomEye{
 		apiKey:      apiKey,
 		accessToken: accessToken,
+		cassette:    cassetteFromEnv(),
+		tracer:      tracerFromEnv(),
+	}
+	for _, opt := range opts {
+		opt(z)
 	}
+	z.setup()
+	return z
 }
 
 // New creates instance of ZoomEye
-func New() *ZoomEye {
-	return &ZoomEye{}
+func New(opts ...Option) *ZoomEye {
+	return NewWithKey("", "", opts...)
 }
@@ -0,0 +1,352 @@
+package zoomeyetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	fixtureTime = time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	fixtureApps = []struct {
+		app, version, service, os string
+		port                      int
+		banner                    string
+	}{
+		{"nginx", "1.18.0", "http", "Linux", 80, "HTTP/1.1 200 OK\r\nServer: nginx/1.18.0\r\nContent-Type: text/html\r\n\r\n"},
+		{"OpenSSH", "7.4", "ssh", "Linux", 22, "SSH-2.0-OpenSSH_7.4\r\n"},
+		{"Apache httpd", "2.4.6", "http", "CentOS", 8080, "HTTP/1.1 200 OK\r\nServer: Apache/2.4.6 (CentOS)\r\n\r\n"},
+		{"vsftpd", "3.0.2", "ftp", "Unix", 21, "220 (vsFTPd 3.0.2)\r\n"},
+		{"MySQL", "5.7.33", "mysql", "Linux", 3306, "J\x00\x00\x00\n5.7.33\x00"},
+	}
+	fixtureWebApps = []struct {
+		webapp, server, framework string
+	}{
+		{"WordPress", "nginx", "PHP"},
+		{"Drupal", "Apache httpd", "PHP"},
+		{"Confluence", "Apache Tomcat", "Java"},
+	}
+	fixtureGeos = []struct {
+		country, code, city string
+		asn                 int
+		org                 string
+		lat, lon            float64
+	}{
+		{"China", "CN", "Beijing", 4808, "China Unicom", 39.9042, 116.4074},
+		{"United States", "US", "Los Angeles", 15169, "Google LLC", 34.0522, -118.2437},
+		{"Japan", "JP", "Tokyo", 2516, "KDDI", 35.6762, 139.6503},
+		{"Germany", "DE", "Berlin", 3320, "Deutsche Telekom AG", 52.52, 13.405},
+		{"Brazil", "BR", "Sao Paulo", 28573, "Claro S.A.", -23.5505, -46.6333},
+	}
+	hostFacetFields = map[string]string{
+		"product": "portinfo.app",
+		"app":     "portinfo.app",
+		"device":  "portinfo.device",
+		"service": "portinfo.service",
+		"os":      "portinfo.os",
+		"port":    "portinfo.port",
+		"country": "geoinfo.country.names.en",
+		"city":    "geoinfo.city.names.en",
+	}
+	webFacetFields = map[string]string{
+		"webapp":    "webapp",
+		"component": "component",
+		"framework": "framework",
+		"frontend":  "frontend",
+		"server":    "server",
+		"waf":       "waf",
+		"os":        "system",
+		"country":   "geoinfo.country.names.en",
+		"city":      "geoinfo.city.names.en",
+	}
+	hostDorkFields = map[string]string{
+		"app":      "portinfo.app",
+		"ver":      "portinfo.version",
+		"service":  "portinfo.service",
+		"port":     "portinfo.port",
+		"os":       "portinfo.os",
+		"device":   "portinfo.device",
+		"hostname": "portinfo.hostname",
+		"ip":       "ip",
+		"country":  "geoinfo.country.names.en",
+		"city":     "geoinfo.city.names.en",
+		"asn":      "geoinfo.asn",
+		"org":      "geoinfo.organization",
+	}
+	webDorkFields = map[string]string{
+		"app":       "webapp",
+		"webapp":    "webapp",
+		"server":    "server",
+		"framework": "framework",
+		"site":      "site",
+		"title":     "title",
+		"ip":        "ip",
+		"country":   "geoinfo.country.names.en",
+		"city":      "geoinfo.city.names.en",
+	}
+)
+
+func geoinfo(i int) map[string]interface{} {
+	g := fixtureGeos[i%len(fixtureGeos)]
+	return map[string]interface{}{
+		"country": map[string]interface{}{
+			"code":  g.code,
+			"names": map[string]interface{}{"en": g.country},
+		},
+		"city": map[string]interface{}{
+			"names": map[string]interface{}{"en": g.city},
+		},
+		"asn":          g.asn,
+		"organization": g.org,
+		"location": map[string]interface{}{
+			"lat": g.lat,
+			"lon": g.lon,
+		},
+	}
+}
+
+func timestamp(t time.Time) string {
+	return t.Format("2006-01-02T15:04:05")
+}
+
+// Hosts generates n fixture matches of host search
+func Hosts(n int) []map[string]interface{} {
+	matches := make([]map[string]interface{}, n)
+	for i := range matches {
+		a := fixtureApps[i%len(fixtureApps)]
+		matches[i] = map[string]interface{}{
+			"ip": fmt.Sprintf("10.%d.%d.%d", i/65536%256, i/256%256, i%256+1),
+			"portinfo": map[string]interface{}{
+				"port":     a.port,
+				"service":  a.service,
+				"app":      a.app,
+				"version":  a.version,
+				"os":       a.os,
+				"device":   "",
+				"hostname": fmt.Sprintf("host%d.example.com", i),
+				"banner":   a.banner,
+			},
+			"geoinfo":   geoinfo(i / len(fixtureApps)),
+			"timestamp": timestamp(fixtureTime.Add(-time.Duration(i) * time.Hour)),
+		}
+	}
+	return matches
+}
+
+// Webs generates n fixture matches of web search
+func Webs(n int) []map[string]interface{} {
+	matches := make([]map[string]interface{}, n)
+	for i := range matches {
+		var (
+			a    = fixtureWebApps[i%len(fixtureWebApps)]
+			site = fmt.Sprintf("www.example%d.com", i)
+		)
+		matches[i] = map[string]interface{}{
+			"ip":          []interface{}{fmt.Sprintf("172.16.%d.%d", i/256%256, i%256+1)},
+			"site":        site,
+			"domains":     []interface{}{site},
+			"title":       fmt.Sprintf("%s site %d", a.webapp, i),
+			"keywords":    strings.ToLower(a.webapp),
+			"description": "",
+			"headers":     fmt.Sprintf("HTTP/1.1 200 OK\r\nServer: %s\r\n\r\n", a.server),
+			"webapp":      []interface{}{map[string]interface{}{"name": a.webapp, "version": ""}},
+			"server":      []interface{}{map[string]interface{}{"name": a.server, "version": ""}},
+			"framework":   []interface{}{map[string]interface{}{"name": a.framework, "version": ""}},
+			"component":   []interface{}{},
+			"frontend":    []interface{}{},
+			"waf":         []interface{}{},
+			"db":          []interface{}{},
+			"system":      []interface{}{map[string]interface{}{"name": "Linux", "version": ""}},
+			"geoinfo":     geoinfo(i),
+			"timestamp":   timestamp(fixtureTime.Add(-time.Duration(i) * time.Hour)),
+		}
+	}
+	return matches
+}
+
+// History generates n fixture records of device history for ip, the newest record is the first,
+// and the product version of each port changes every three probes
+func History(ip string, n int) []map[string]interface{} {
+	records := make([]map[string]interface{}, n)
+	for i := range records {
+		var (
+			a   = fixtureApps[i%3]
+			ver = a.version
+		)
+		if rev := (n - 1 - i) / 9; rev > 0 {
+			ver += "." + strconv.Itoa(rev)
+		}
+		records[i] = map[string]interface{}{
+			"ip": ip,
+			"portinfo": map[string]interface{}{
+				"port":     a.port,
+				"service":  a.service,
+				"product":  a.app,
+				"version":  ver,
+				"hostname": "",
+			},
+			"raw_data":  strings.ReplaceAll(a.banner, a.version, ver),
+			"geoinfo":   geoinfo(0),
+			"timestamp": timestamp(fixtureTime.Add(-time.Duration(i) * 24 * time.Hour)),
+		}
+	}
+	return records
+}
+
+func lookup(m map[string]interface{}, expr string) interface{} {
+	var val interface{} = m
+	for _, k := range strings.Split(expr, ".") {
+		curr, ok := val.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if val, ok = curr[k]; !ok {
+			return nil
+		}
+	}
+	return val
+}
+
+func values(o interface{}) []string {
+	switch o := o.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		s := make([]string, 0, len(o))
+		for _, v := range o {
+			if m, ok := v.(map[string]interface{}); ok {
+				v = m["name"]
+			}
+			s = append(s, fmt.Sprintf("%v", v))
+		}
+		return s
+	case []map[string]interface{}:
+		s := make([]string, 0, len(o))
+		for _, v := range o {
+			s = append(s, fmt.Sprintf("%v", v["name"]))
+		}
+		return s
+	case string:
+		return []string{o}
+	default:
+		return []string{fmt.Sprintf("%v", o)}
+	}
+}
+
+func tokenize(dork string) []string {
+	var (
+		tokens  []string
+		builder strings.Builder
+		quoted  bool
+	)
+	for _, r := range dork {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ' ' && !quoted:
+			if builder.Len() > 0 {
+				tokens = append(tokens, builder.String())
+				builder.Reset()
+			}
+		default:
+			builder.WriteRune(r)
+		}
+	}
+	if builder.Len() > 0 {
+		tokens = append(tokens, builder.String())
+	}
+	return tokens
+}
+
+func matchToken(m map[string]interface{}, token string, fields map[string]string) bool {
+	kv := strings.SplitN(token, ":", 2)
+	if len(kv) == 2 {
+		var (
+			key = strings.ToLower(kv[0])
+			val = kv[1]
+		)
+		switch key {
+		case "after":
+			return fmt.Sprintf("%v", m["timestamp"]) > val
+		case "before":
+			return fmt.Sprintf("%v", m["timestamp"]) < val
+		}
+		if field, ok := fields[key]; ok {
+			for _, v := range values(lookup(m, field)) {
+				if strings.EqualFold(v, val) {
+					return true
+				}
+			}
+			if key == "country" {
+				return strings.EqualFold(fmt.Sprintf("%v", lookup(m, "geoinfo.country.code")), val)
+			}
+			return false
+		}
+	}
+	b, _ := json.Marshal(m)
+	return strings.Contains(strings.ToLower(string(b)), strings.ToLower(token))
+}
+
+func filterMatches(matches []map[string]interface{}, dork string, fields map[string]string) []map[string]interface{} {
+	var (
+		tokens   = tokenize(dork)
+		filtered = make([]map[string]interface{}, 0, len(matches))
+	)
+	for _, m := range matches {
+		ok := true
+		for _, t := range tokens {
+			var not bool
+			if strings.HasPrefix(t, "-") {
+				t, not = t[1:], true
+			} else {
+				t = strings.TrimPrefix(t, "+")
+			}
+			if t != "" && matchToken(m, t, fields) == not {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
+
+func facets(matches []map[string]interface{}, names string, fields map[string]string) map[string]interface{} {
+	result := make(map[string]interface{})
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		field, ok := fields[name]
+		if !ok {
+			continue
+		}
+		if name == "app" {
+			name = "product"
+		}
+		counts := make(map[string]uint64)
+		for _, m := range matches {
+			for _, v := range values(lookup(m, field)) {
+				counts[v]++
+			}
+		}
+		items := make([]map[string]interface{}, 0, len(counts))
+		for k, v := range counts {
+			items = append(items, map[string]interface{}{"name": k, "count": v})
+		}
+		sort.Slice(items, func(i, j int) bool {
+			if ci, cj := items[i]["count"].(uint64), items[j]["count"].(uint64); ci != cj {
+				return ci > cj
+			}
+			return items[i]["name"].(string) < items[j]["name"].(string)
+		})
+		if len(items) > 10 {
+			items = items[:10]
+		}
+		result[name] = items
+	}
+	return result
+}
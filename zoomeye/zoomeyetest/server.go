@@ -0,0 +1,365 @@
+// Package zoomeyetest provides in-process fake ZoomEye API server for testing
+package zoomeyetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default credentials accepted by the fake server
+const (
+	APIKey   = "ZOOMEYE-TEST-API-KEY"
+	Username = "username@zoomeye.org"
+	Password = "password"
+)
+
+const pageSize = 20
+
+// Fault represents error injected into responses of the fake server
+type Fault struct {
+	Path    string // matched request path, empty matches all paths
+	Page    int    // matched page of search, zero matches all pages
+	Status  int    // status code of response
+	Times   int    // times to inject, zero means always
+	Message string // message of error result
+}
+
+func (f *Fault) match(r *http.Request) bool {
+	if f.Path != "" && f.Path != r.URL.Path {
+		return false
+	}
+	return f.Page == 0 || strconv.Itoa(f.Page) == r.URL.Query().Get("page")
+}
+
+// Server represents in-process fake ZoomEye API server
+type Server struct {
+	*httptest.Server
+	APIKey   string
+	Username string
+	Password string
+	Plan     string
+	Interval string
+	hosts    []map[string]interface{}
+	webs     []map[string]interface{}
+	history  map[string][]map[string]interface{}
+	search   int
+	stats    int
+	limit    int
+	period   time.Duration
+	window   time.Time
+	hits     int
+	latency  time.Duration
+	faults   []*Fault
+	tokens   map[string]bool
+	requests map[string]int
+	mu       sync.Mutex
+}
+
+// Option represents option of the fake server
+type Option func(*Server)
+
+// WithHosts replaces fixture matches of host search
+func WithHosts(matches ...map[string]interface{}) Option {
+	return func(s *Server) {
+		s.hosts = matches
+	}
+}
+
+// WithWebs replaces fixture matches of web search
+func WithWebs(matches ...map[string]interface{}) Option {
+	return func(s *Server) {
+		s.webs = matches
+	}
+}
+
+// WithHistory sets fixture records of device history for ip
+func WithHistory(ip string, records ...map[string]interface{}) Option {
+	return func(s *Server) {
+		s.history[ip] = records
+	}
+}
+
+// WithQuota sets remaining search and stats quota of the account
+func WithQuota(search, stats int) Option {
+	return func(s *Server) {
+		s.search, s.stats = search, stats
+	}
+}
+
+// WithPlan sets plan of the account
+func WithPlan(plan string) Option {
+	return func(s *Server) {
+		s.Plan = plan
+	}
+}
+
+// WithRateLimit allows at most n requests per period, others are rejected with 429
+func WithRateLimit(n int, period time.Duration) Option {
+	return func(s *Server) {
+		s.limit, s.period = n, period
+	}
+}
+
+// WithLatency delays every response
+func WithLatency(d time.Duration) Option {
+	return func(s *Server) {
+		s.latency = d
+	}
+}
+
+// WithFaults injects errors into responses
+func WithFaults(faults ...*Fault) Option {
+	return func(s *Server) {
+		s.faults = append(s.faults, faults...)
+	}
+}
+
+// Inject injects errors into responses of the running server
+func (s *Server) Inject(faults ...*Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, faults...)
+}
+
+// Quota returns remaining search and stats quota of the account
+func (s *Server) Quota() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.search, s.stats
+}
+
+// Requests returns number of requests received on path, empty path counts all requests
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if path == "" {
+		var n int
+		for _, v := range s.requests {
+			n += v
+		}
+		return n
+	}
+	return s.requests[path]
+}
+
+func (s *Server) write(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *Server) error(w http.ResponseWriter, status int, code, msg string) {
+	s.write(w, status, map[string]string{
+		"error":   code,
+		"message": msg,
+		"url":     "https://www.zoomeye.org/doc",
+	})
+}
+
+func (s *Server) fault(r *http.Request) *Fault {
+	for i, f := range s.faults {
+		if !f.match(r) {
+			continue
+		}
+		if f.Times > 0 {
+			if f.Times--; f.Times == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+func (s *Server) limited() bool {
+	if s.limit <= 0 {
+		return false
+	}
+	if now := time.Now(); now.Sub(s.window) >= s.period {
+		s.window, s.hits = now, 0
+	}
+	s.hits++
+	return s.hits > s.limit
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if key := r.Header.Get("API-KEY"); key != "" {
+		return key == s.APIKey
+	}
+	auth := r.Header.Get("Authorization")
+	return strings.HasPrefix(auth, "JWT ") && s.tokens[strings.TrimPrefix(auth, "JWT ")]
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.latency > 0 {
+		time.Sleep(s.latency)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests[r.URL.Path]++
+	if s.limited() {
+		s.error(w, http.StatusTooManyRequests, "request_limit", "too many requests")
+		return
+	}
+	if f := s.fault(r); f != nil {
+		msg := f.Message
+		if msg == "" {
+			msg = http.StatusText(f.Status)
+		}
+		s.error(w, f.Status, "injected_fault", msg)
+		return
+	}
+	if r.URL.Path == "/user/login" {
+		s.login(w, r)
+		return
+	}
+	if !s.authorized(r) {
+		s.error(w, http.StatusUnauthorized, "bad_request", "token or api key is invalid")
+		return
+	}
+	switch r.URL.Path {
+	case "/resources-info":
+		s.resourcesInfo(w)
+	case "/host/search":
+		s.dorkSearch(w, r, s.hosts, hostDorkFields, hostFacetFields)
+	case "/web/search":
+		s.dorkSearch(w, r, s.webs, webDorkFields, webFacetFields)
+	case "/both/search":
+		s.historyIP(w, r)
+	default:
+		s.error(w, http.StatusNotFound, "not_found", "resource not found")
+	}
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&data) != nil {
+		s.error(w, http.StatusBadRequest, "bad_request", "invalid request")
+		return
+	}
+	if data.Username != s.Username || data.Password != s.Password {
+		s.error(w, http.StatusUnauthorized, "login_failed", "username or password is incorrect")
+		return
+	}
+	tok := fmt.Sprintf("jwt-%d-%d", len(s.tokens)+1, time.Now().UnixNano())
+	s.tokens[tok] = true
+	s.write(w, http.StatusOK, map[string]string{
+		"access_token": tok,
+	})
+}
+
+func (s *Server) resourcesInfo(w http.ResponseWriter) {
+	s.write(w, http.StatusOK, map[string]interface{}{
+		"plan": s.Plan,
+		"resources": map[string]interface{}{
+			"interval": s.Interval,
+			"search":   s.search,
+			"stats":    s.stats,
+		},
+	})
+}
+
+func (s *Server) dorkSearch(w http.ResponseWriter, r *http.Request, data []map[string]interface{}, dorkFields, facetFields map[string]string) {
+	var (
+		query    = r.URL.Query()
+		page, _  = strconv.Atoi(query.Get("page"))
+		matches  = filterMatches(data, query.Get("query"), dorkFields)
+		total    = len(matches)
+		start    = (page - 1) * pageSize
+		pageData = []map[string]interface{}{}
+	)
+	if page <= 0 {
+		start = 0
+	}
+	if start < total {
+		end := start + pageSize
+		if end > total {
+			end = total
+		}
+		pageData = matches[start:end]
+	}
+	if n := len(pageData); n > 0 {
+		if s.search < n {
+			s.error(w, http.StatusPaymentRequired, "credits_insufficent", "credits is insufficent")
+			return
+		}
+		s.search -= n
+	}
+	s.write(w, http.StatusOK, map[string]interface{}{
+		"available": total,
+		"total":     total,
+		"matches":   pageData,
+		"facets":    facets(matches, query.Get("facets"), facetFields),
+	})
+}
+
+func (s *Server) historyIP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("history") != "true" {
+		s.error(w, http.StatusBadRequest, "bad_request", "invalid request")
+		return
+	}
+	data, ok := s.history[query.Get("ip")]
+	if !ok {
+		data = []map[string]interface{}{}
+	}
+	if n := len(data); n > 0 {
+		if s.search < n {
+			s.error(w, http.StatusPaymentRequired, "credits_insufficent", "credits is insufficent")
+			return
+		}
+		s.search -= n
+	}
+	s.write(w, http.StatusOK, map[string]interface{}{
+		"count": len(data),
+		"data":  data,
+	})
+}
+
+// NewServer starts and returns a new fake ZoomEye API server,
+// by default it serves 200 host matches, 100 web matches and 30 history records of 1.2.3.4
+func NewServer(opts ...Option) *Server {
+	s := newServer(opts...)
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// NewTLSServer starts and returns a new fake ZoomEye API server using TLS,
+// its certificate can be got from s.Certificate()
+func NewTLSServer(opts ...Option) *Server {
+	s := newServer(opts...)
+	s.Server = httptest.NewTLSServer(s)
+	return s
+}
+
+func newServer(opts ...Option) *Server {
+	s := &Server{
+		APIKey:   APIKey,
+		Username: Username,
+		Password: Password,
+		Plan:     "vip",
+		Interval: "month",
+		hosts:    Hosts(200),
+		webs:     Webs(100),
+		history: map[string][]map[string]interface{}{
+			"1.2.3.4": History("1.2.3.4", 30),
+		},
+		search:   10000,
+		stats:    1000,
+		tokens:   make(map[string]bool),
+		requests: make(map[string]int),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
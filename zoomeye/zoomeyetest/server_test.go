@@ -0,0 +1,65 @@
+package zoomeyetest_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gyyyy/ZoomEye-go/zoomeye"
+	"github.com/gyyyy/ZoomEye-go/zoomeye/zoomeyetest"
+)
+
+func TestServer(t *testing.T) {
+	srv := zoomeyetest.NewServer(zoomeyetest.WithQuota(1000, 100))
+	defer srv.Close()
+	zoom := zoomeye.New(zoomeye.WithBaseURL(srv.URL))
+	if _, err := zoom.Login(zoomeyetest.Username, zoomeyetest.Password); err != nil {
+		t.FailNow()
+	}
+	info, err := zoom.ResourcesInfo()
+	if err != nil || info.Plan != "vip" || info.Resources.Search != 1000 {
+		t.FailNow()
+	}
+	result, err := zoom.DorkSearch("nginx country:China", 1, "host", "app,country")
+	if err != nil || result.Total != 8 || len(result.Facets["product"]) != 1 {
+		t.FailNow()
+	}
+	results, err := zoom.MultiPageSearch("", 8, "host", "")
+	if err != nil || len(results) != 8 || results[8].Matches[0].FindString("ip") != "10.0.0.141" {
+		t.FailNow()
+	}
+	if search, _ := srv.Quota(); search != 1000-8-8*20 {
+		t.Fail()
+	}
+	history, err := zoom.HistoryIP("1.2.3.4")
+	if err != nil || history.Count != 30 {
+		t.Fail()
+	}
+	if _, err = zoomeye.NewWithKey("invalid", "", zoomeye.WithBaseURL(srv.URL)).ResourcesInfo(); err == nil {
+		t.Fail()
+	}
+}
+
+func TestServerFaults(t *testing.T) {
+	srv := zoomeyetest.NewServer(
+		zoomeyetest.WithFaults(&zoomeyetest.Fault{Path: "/web/search", Page: 2, Status: http.StatusInternalServerError, Times: 1}),
+		zoomeyetest.WithRateLimit(3, time.Minute),
+	)
+	defer srv.Close()
+	zoom := zoomeye.NewWithKey(zoomeyetest.APIKey, "", zoomeye.WithBaseURL(srv.URL))
+	if _, err := zoom.DorkSearch("", 2, "web", ""); err == nil {
+		t.Fail()
+	}
+	if _, err := zoom.DorkSearch("", 2, "web", ""); err != nil {
+		t.Fail()
+	}
+	if _, err := zoom.DorkSearch("", 3, "web", ""); err != nil {
+		t.Fail()
+	}
+	if _, err := zoom.DorkSearch("", 4, "web", ""); err == nil {
+		t.Fail()
+	}
+	if srv.Requests("/web/search") != 4 {
+		t.Fail()
+	}
+}